@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/boppreh/steamgrid/pkg/steamgrid"
+)
+
+// Subcommand of the CLI, e.g. "steamgrid verify". All commands take the same
+// flags, plain "steamgrid" is the same as "steamgrid fetch".
+type command struct {
+	name        string
+	args        string
+	description string
+	// Nil for fetch, which is the normal run.
+	run func(options steamgrid.Options, args []string) error
+}
+
+var commands = []command{
+	{"fetch", "[steam dir]", "Download artwork and apply overlays (the default)", nil},
+	{"restore", "", "Put back the clean images from the backups, removing the overlays", func(options steamgrid.Options, args []string) error {
+		return steamgrid.Restore(options)
+	}},
+	{"verify", "", "Check the grid images and backups, removing corrupt ones", func(options steamgrid.Options, args []string) error {
+		return steamgrid.Verify(options)
+	}},
+	{"export", "<file.zip>", "Save the grid images of all users to a zip file", func(options steamgrid.Options, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Usage: steamgrid export <file.zip>")
+		}
+		return steamgrid.Export(options, args[0])
+	}},
+	{"users", "", "List the Steam users and their grid folders", func(options steamgrid.Options, args []string) error {
+		return steamgrid.PrintUsers(options)
+	}},
+	{"add-shortcuts", "<dir>", "Add the games in a folder to Steam and download their artwork", func(options steamgrid.Options, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Usage: steamgrid add-shortcuts <dir>")
+		}
+		// Partial and auth failures were reported and keep their exit codes.
+		return steamgrid.AddShortcuts(context.Background(), options, args[0])
+	}},
+	{"setup", "", "Ask for the main options and save them as the defaults", func(options steamgrid.Options, args []string) error {
+		return runWizard(configFile())
+	}},
+	{"help", "[topic]", "Show detailed help with examples", func(options steamgrid.Options, args []string) error {
+		if len(args) > 1 {
+			return errors.New("Usage: steamgrid help [topic]")
+		} else if len(args) == 1 {
+			return printHelp(args[0])
+		}
+		return printHelp("")
+	}},
+}
+
+// Returns the command with the name, or nil.
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// Prints the commands before the flags.
+func printUsage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: steamgrid [command] [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-14v %-12v %v\n", c.name, c.args, c.description)
+	}
+	fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+	flag.PrintDefaults()
+}
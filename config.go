@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/boppreh/steamgrid/pkg/steamgrid"
+)
+
+// Returns the path of the config file with the default flag values, e.g.
+// ~/.config/steamgrid/config.json or %APPDATA%\steamgrid\config.json.
+func configFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = steamgrid.ExecutableDir()
+	}
+	return filepath.Join(dir, "steamgrid", "config.json")
+}
+
+// Reads the flag values saved in the config file, as flag name -> value.
+// Returns an empty map if there's no config file.
+func readConfig(path string) (map[string]string, error) {
+	values := make(map[string]string)
+	configBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return values, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(configBytes, &values)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Sets the flags from the config file, except the ones given in the command
+// line, which take precedence.
+func loadConfig(path string) error {
+	values, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	given := givenFlags()
+	for name, value := range values {
+		if given[name] {
+			continue
+		}
+		err := flag.Set(name, value)
+		if err != nil {
+			return errors.New("Invalid value for " + name + " in " + path + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// Returns the names of the flags that were set, e.g. in the command line.
+func givenFlags() map[string]bool {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	return given
+}
+
+// Writes the flag values to the config file.
+func saveConfig(path string, values map[string]string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	configBytes, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	// May contain api keys, so only readable by the user.
+	return ioutil.WriteFile(path, configBytes, 0600)
+}
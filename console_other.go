@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// Programs are only started in a console of their own on Windows.
+func ownsConsole() bool {
+	return false
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var getConsoleProcessList = syscall.NewLazyDLL("kernel32.dll").NewProc("GetConsoleProcessList")
+
+// Returns true if steamgrid got a console window of its own, i.e. it was
+// started by double clicking it. From a terminal the console is shared with
+// the shell.
+func ownsConsole() bool {
+	processes := make([]uint32, 2)
+	count, _, _ := getConsoleProcessList.Call(uintptr(unsafe.Pointer(&processes[0])), uintptr(len(processes)))
+	return count == 1
+}
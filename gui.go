@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"sync"
+
+	"github.com/boppreh/steamgrid/pkg/steamgrid"
+)
+
+const guiTemplate = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>steamgrid</title>
+{{if .Running}}<meta http-equiv="refresh" content="2">{{end}}
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; }
+label { display: block; margin: 0.5em 0; }
+input[type=text] { width: 100%; }
+</style></head>
+<body>
+<h1>steamgrid</h1>
+{{if .Running}}
+<p>Running... The progress is shown in the console window.</p>
+{{else}}
+{{if .Done}}<p><b>{{.Result}}</b> Restart Steam to see the new images.</p>{{end}}
+<form method="post" action="/run">
+<input type="hidden" name="token" value="{{.Token}}">
+<label>SteamGridDB api key (<a href="https://www.steamgriddb.com/profile/preferences" target="_blank">get one</a>)
+<input type="text" name="steamgriddb" value="{{.Options.SteamGridDBApiKey}}"></label>
+<label>IGDB client ID <input type="text" name="igdbclient" value="{{.Options.IGDBClient}}"></label>
+<label>IGDB secret <input type="text" name="igdbsecret" value="{{.Options.IGDBSecret}}"></label>
+<p>Art styles:</p>
+<label><input type="checkbox" name="banner" {{if not .Options.SkipBanner}}checked{{end}}> Banners</label>
+<label><input type="checkbox" name="cover" {{if not .Options.SkipCover}}checked{{end}}> Covers</label>
+<label><input type="checkbox" name="hero" {{if not .Options.SkipHero}}checked{{end}}> Heroes</label>
+<label><input type="checkbox" name="logo" {{if not .Options.SkipLogo}}checked{{end}}> Logos</label>
+<p>Options:</p>
+<label><input type="checkbox" name="nonsteamonly" {{if .Options.NonSteamOnly}}checked{{end}}> Only non-Steam games</label>
+<label><input type="checkbox" name="nooverlays" {{if .Options.NoOverlays}}checked{{end}}> No overlays</label>
+<label><input type="checkbox" name="closesteam" {{if .Options.CloseSteam}}checked{{end}}> Close and reopen Steam</label>
+<p><button type="submit">Run</button></p>
+</form>
+{{end}}
+</body></html>`
+
+// Web page to set the main options and start runs, for users who double
+// click the program and can't pass flags.
+type gui struct {
+	mutex      sync.Mutex
+	page       *template.Template
+	configPath string
+	// Keeps other web pages from starting runs.
+	guard   *steamgrid.FormGuard
+	Token   string
+	Options steamgrid.Options
+	Running bool
+	Done    bool
+	Result  string
+}
+
+// Returns true if steamgrid was started without flags in a console of its
+// own, i.e. by double clicking it on Windows, where the GUI is easier to use
+// than flags.
+func shouldStartGUI(args []string) bool {
+	return len(args) == 0 && ownsConsole()
+}
+
+// Serves the GUI on a free local port and opens it in the browser. Runs until
+// the program is closed.
+func RunGUI(options steamgrid.Options, configPath string) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	guard, err := steamgrid.NewFormGuard()
+	if err != nil {
+		return err
+	}
+	g := &gui{page: template.Must(template.New("gui").Parse(guiTemplate)), configPath: configPath, guard: guard, Token: guard.Token(), Options: options}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", g.handleIndex)
+	mux.HandleFunc("/run", g.handleRun)
+
+	addr := listener.Addr().String()
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Other host names pointed at this computer belong to other web
+		// pages, which could read the token.
+		if r.Host != addr && r.Host != "localhost:"+port {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+
+	url := "http://" + addr + "/"
+	fmt.Printf("steamgrid is open in your browser at %v\nClose this window to quit.\n", url)
+	openBrowser(url)
+	return http.Serve(listener, handler)
+}
+
+func (g *gui) handleIndex(w http.ResponseWriter, r *http.Request) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.page.Execute(w, g)
+}
+
+func (g *gui) handleRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if !g.guard.Allows(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	g.mutex.Lock()
+	if g.Running {
+		g.mutex.Unlock()
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	options := g.Options
+	options.SteamGridDBApiKey = r.FormValue("steamgriddb")
+	options.IGDBClient = r.FormValue("igdbclient")
+	options.IGDBSecret = r.FormValue("igdbsecret")
+	options.SkipBanner = r.FormValue("banner") == ""
+	options.SkipCover = r.FormValue("cover") == ""
+	options.SkipHero = r.FormValue("hero") == ""
+	options.SkipLogo = r.FormValue("logo") == ""
+	options.NonSteamOnly = r.FormValue("nonsteamonly") != ""
+	options.NoOverlays = r.FormValue("nooverlays") != ""
+	options.CloseSteam = r.FormValue("closesteam") != ""
+	g.Options = options
+	g.Running = true
+	g.mutex.Unlock()
+
+	err := saveGUIChoices(g.configPath, options)
+	if err != nil {
+		fmt.Println("Could not save the choices for the next run: " + err.Error())
+	}
+
+	go func() {
+		err := steamgrid.Run(context.Background(), options)
+		result := "Done!"
+		if err != nil {
+			result = err.Error()
+		}
+		g.mutex.Lock()
+		g.Running = false
+		g.Done = true
+		g.Result = result
+		g.mutex.Unlock()
+	}()
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Saves the keys and choices of a run like the setup wizard does, so the
+// next double click or scheduled run starts with them.
+func saveGUIChoices(configPath string, options steamgrid.Options) error {
+	values, err := readConfig(configPath)
+	if err != nil {
+		return err
+	}
+	choices := map[string]bool{
+		"skipbanner":   options.SkipBanner,
+		"skipcover":    options.SkipCover,
+		"skiphero":     options.SkipHero,
+		"skiplogo":     options.SkipLogo,
+		"nonsteamonly": options.NonSteamOnly,
+		"no-overlays":  options.NoOverlays,
+		"close-steam":  options.CloseSteam,
+	}
+	for name, chosen := range choices {
+		if chosen {
+			values[name] = "true"
+		} else {
+			delete(values, name)
+		}
+	}
+
+	keys := make(map[string]string)
+	for name, value := range map[string]string{"steamgriddb": options.SteamGridDBApiKey, "igdbclient": options.IGDBClient, "igdbsecret": options.IGDBSecret} {
+		if value != "" {
+			keys[name] = value
+		}
+	}
+	if len(keys) > 0 && storeKeys(keys) != nil {
+		for name, value := range keys {
+			values[name] = value
+		}
+	} else {
+		for name := range keys {
+			delete(values, name)
+		}
+	}
+	return saveConfig(configPath, values)
+}
+
+// Opens the URL in the default browser, ignoring failures since the URL is
+// also printed.
+func openBrowser(url string) {
+	switch runtime.GOOS {
+	case "windows":
+		exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	case "darwin":
+		exec.Command("open", url).Start()
+	default:
+		exec.Command("xdg-open", url).Start()
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Detailed help pages with examples, shown by "steamgrid help <topic>".
+//
+//go:embed help/*.txt
+var helpPages embed.FS
+
+// Returns the names of the help topics.
+func helpTopics() []string {
+	var topics []string
+	files, _ := helpPages.ReadDir("help")
+	for _, file := range files {
+		topic := strings.TrimSuffix(file.Name(), ".txt")
+		if topic != "topics" {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Prints the help page for the topic, or the list of topics if it's empty.
+func printHelp(topic string) error {
+	if topic == "" {
+		topic = "topics"
+	}
+	page, err := helpPages.ReadFile("help/" + topic + ".txt")
+	if err != nil {
+		return errors.New("No help on " + topic + ", topics are: " + strings.Join(helpTopics(), ", "))
+	}
+
+	tmpl, err := template.New(topic).Funcs(template.FuncMap{
+		// Default value of a flag, so examples never go out of date.
+		"flag": func(name string) string {
+			if f := flag.Lookup(name); f != nil {
+				return f.DefValue
+			}
+			return ""
+		},
+	}).Parse(string(page))
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(os.Stdout, struct {
+		Program string
+		Topics  []string
+	}{strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe"), helpTopics()})
+}
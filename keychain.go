@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/boppreh/steamgrid/pkg/steamgrid"
+)
+
+// Flags holding api keys and passwords, which can also come from environment
+// variables and the OS keychain instead of the command line.
+var secretFlags = []string{"steamgriddb", "igdbclient", "igdbsecret", "screenscraperdevid", "screenscraperdevpassword", "screenscraperuser", "screenscraperpassword"}
+
+// The keychain entry holding all keys as a JSON object of flag name -> value,
+// so a run only asks the keychain once.
+const (
+	keychainService = "steamgrid"
+	keychainAccount = "api-keys"
+)
+
+// PowerShell scripts using the Windows PasswordVault, which is stored in the
+// Credential Manager. The value to save is passed in STEAMGRID_KEYS so it
+// doesn't show up in the process list.
+const windowsVaultLoad = `$ErrorActionPreference = 'Stop'
+[void][Windows.Security.Credentials.PasswordVault, Windows.Security.Credentials, ContentType = WindowsRuntime]
+$credential = (New-Object Windows.Security.Credentials.PasswordVault).Retrieve('steamgrid', 'api-keys')
+$credential.RetrievePassword()
+$credential.Password`
+
+const windowsVaultSave = `$ErrorActionPreference = 'Stop'
+[void][Windows.Security.Credentials.PasswordVault, Windows.Security.Credentials, ContentType = WindowsRuntime]
+(New-Object Windows.Security.Credentials.PasswordVault).Add((New-Object Windows.Security.Credentials.PasswordCredential('steamgrid', 'api-keys', $env:STEAMGRID_KEYS)))`
+
+// Returns the environment variable for a secret flag, e.g.
+// STEAMGRID_IGDBSECRET for --igdbsecret.
+func secretEnvVar(name string) string {
+	return "STEAMGRID_" + strings.ToUpper(name)
+}
+
+// Returns the name of the keychain used on this system, for messages.
+func keychainName() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "the Windows Credential Manager"
+	case "darwin":
+		return "the macOS Keychain"
+	default:
+		return "the Secret Service keyring (libsecret)"
+	}
+}
+
+// Returns the keys stored in the keychain, or an empty map if there are none
+// or the keychain is not available.
+func readStoredKeys() map[string]string {
+	keys := make(map[string]string)
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", windowsVaultLoad)
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w")
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", keychainAccount)
+	}
+	output, err := cmd.Output()
+	if err == nil {
+		json.Unmarshal(output, &keys)
+	}
+	return keys
+}
+
+// Adds the keys to the ones stored in the keychain.
+func storeKeys(keys map[string]string) error {
+	stored := readStoredKeys()
+	for name, value := range keys {
+		stored[name] = value
+	}
+	storedBytes, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", windowsVaultSave)
+		cmd.Env = append(os.Environ(), "STEAMGRID_KEYS="+string(storedBytes))
+	case "darwin":
+		// Arguments show up in the process list, so the command is given
+		// to the interactive mode in stdin instead, with the value in hex to
+		// avoid quoting it.
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %v -a %v -X %x\n", keychainService, keychainAccount, storedBytes))
+	default:
+		cmd = exec.Command("secret-tool", "store", "--label=steamgrid api keys", "service", keychainService, "account", keychainAccount)
+		cmd.Stdin = strings.NewReader(string(storedBytes))
+	}
+	output, err := cmd.CombinedOutput()
+	if err == nil && runtime.GOOS == "darwin" && strings.Contains(string(output), "security: ") {
+		// The interactive mode exits with 0 even if the command failed.
+		err = errors.New("security failed")
+	}
+	if err != nil {
+		return errors.New("Could not save the keys in " + keychainName() + ": " + strings.TrimSpace(err.Error()+" "+string(output)))
+	}
+	return nil
+}
+
+// Sets the secret flags not given in the command line from their environment
+// variables, or else from the keychain.
+func loadSecrets() {
+	given := givenFlags()
+	var stored map[string]string
+	for _, name := range secretFlags {
+		if given[name] {
+			continue
+		}
+		if value := os.Getenv(secretEnvVar(name)); value != "" {
+			flag.Set(name, value)
+			continue
+		}
+		if stored == nil {
+			stored = readStoredKeys()
+		}
+		if value := stored[name]; value != "" {
+			flag.Set(name, value)
+		}
+	}
+}
+
+// Checks the keys given in the options and saves them in the keychain, for
+// --save-keys.
+func saveKeys(options steamgrid.Options) error {
+	if options.SteamGridDBApiKey != "" {
+		err := steamgrid.CheckSteamGridDBKey(options.SteamGridDBApiKey)
+		if err != nil {
+			return err
+		}
+	}
+	if options.IGDBClient != "" || options.IGDBSecret != "" {
+		err := steamgrid.CheckIGDBKeys(options.IGDBClient, options.IGDBSecret)
+		if err != nil {
+			return err
+		}
+	}
+
+	keys := make(map[string]string)
+	for _, name := range secretFlags {
+		if value := flag.Lookup(name).Value.String(); value != "" {
+			keys[name] = value
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("No keys given, e.g. steamgrid --steamgriddb <api key> --save-keys")
+	}
+	return storeKeys(keys)
+}
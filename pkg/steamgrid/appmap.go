@@ -0,0 +1,49 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strconv"
+)
+
+// Games that can't be found by their own appID or name, like mods and
+// playtests, mapped by ID to the name to search for or to the appID of a
+// parent game whose artwork is used instead. From --app-map.
+var appMap = map[string]string{}
+
+// Loads the app map from a JSON file, an object of ID -> name or appID, e.g.
+// {"17520": "Synergy", "2435490": "1086940"}.
+func loadAppMap(path string) error {
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var mapping map[string]string
+	err = json.Unmarshal(fileBytes, &mapping)
+	if err != nil {
+		return errors.New("Invalid app map file " + path + ": " + err.Error())
+	}
+	for id, target := range mapping {
+		if target == "" {
+			return errors.New("Empty name for " + id + " in app map " + path)
+		}
+	}
+	appMap = mapping
+	return nil
+}
+
+// Returns true if the app map target is an appID instead of a name.
+func isAppID(target string) bool {
+	_, err := strconv.ParseUint(target, 10, 32)
+	return err == nil
+}
+
+// Returns the appID of the parent game the game is mapped to, if any.
+func mappedParent(game *Game) (string, bool) {
+	target, ok := appMap[game.ID]
+	if !ok || !isAppID(target) || target == game.ID {
+		return "", false
+	}
+	return target, true
+}
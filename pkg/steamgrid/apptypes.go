@@ -0,0 +1,133 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// App types that --exclude-types accepts, with the store types they match.
+// Tools and servers rarely have a store page, so they're mostly recognized by
+// name.
+var excludableTypes = map[string][]string{
+	"dlc":        {"dlc"},
+	"demo":       {"demo"},
+	"soundtrack": {"music"},
+	"video":      {"video", "series", "episode"},
+	"tool":       {"tool"},
+	"server":     {"server"},
+}
+
+// Words in the names of apps without a store page that tell their type.
+var appTypeNameHints = []struct {
+	hint    string
+	appType string
+}{
+	{"dedicated server", "server"},
+	{" server", "server"},
+	{"soundtrack", "music"},
+	{"creation kit", "tool"},
+	{" sdk", "tool"},
+	{" editor", "tool"},
+	{"modding tool", "tool"},
+}
+
+// Returns the path of the cached app types in the user cache folder.
+func appTypesCachePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "steamgrid", "apptypes.json"), nil
+}
+
+// Returns the store types for a comma separated list of excludable types.
+func parseExcludedTypes(typeList string) (map[string]bool, error) {
+	excluded := map[string]bool{}
+	for _, name := range strings.Split(typeList, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		storeTypes, ok := excludableTypes[name]
+		if !ok {
+			return nil, errors.New("Unknown app type " + name + ", must be one of dlc, demo, soundtrack, video, tool or server")
+		}
+		for _, storeType := range storeTypes {
+			excluded[storeType] = true
+		}
+	}
+	return excluded, nil
+}
+
+// Guesses the type of an app without a store page by its name, or "" if it's
+// probably a game.
+func guessAppType(name string) string {
+	name = strings.ToLower(name)
+	for _, hint := range appTypeNameHints {
+		if strings.Contains(name, hint.hint) {
+			return hint.appType
+		}
+	}
+	return ""
+}
+
+// Removes the Steam apps whose type is excluded. Types are looked up in the
+// store once and cached, since they never change. Apps whose type can't be
+// fetched are kept.
+func removeExcludedTypes(games map[string]*Game, excluded map[string]bool) {
+	cachePath, err := appTypesCachePath()
+	if err != nil {
+		return
+	}
+	appTypes := map[string]string{}
+	if cacheBytes, err := ioutil.ReadFile(cachePath); err == nil {
+		json.Unmarshal(cacheBytes, &appTypes)
+	}
+
+	fetched := 0
+	removed := 0
+	for id, game := range games {
+		if game.Custom {
+			continue
+		}
+		appType, ok := appTypes[id]
+		if !ok {
+			details, err := getAppDetails(httpClient, id)
+			if err != nil {
+				// Probably rate limited, try again on the next run.
+				continue
+			}
+			if details != nil {
+				appType = details.Type
+			}
+			appTypes[id] = appType
+			fetched++
+		}
+		if appType == "" {
+			name := game.Name
+			if name == "" {
+				name = appListName(id, true)
+			}
+			appType = guessAppType(name)
+		}
+		if excluded[appType] {
+			delete(games, id)
+			removed++
+		}
+	}
+
+	if fetched > 0 {
+		cacheBytes, err := json.Marshal(appTypes)
+		if err == nil && os.MkdirAll(filepath.Dir(cachePath), 0777) == nil {
+			writeFileAtomic(cachePath, cacheBytes, 0666)
+		}
+	}
+	if removed > 0 {
+		fmt.Printf("Skipping %v apps by type.\n", removed)
+	}
+}
@@ -0,0 +1,112 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sort"
+)
+
+// Built-in art styles, in the order they are shown.
+var builtinArtStyles = []string{"Banner", "Cover", "Hero", "Logo"}
+
+// SteamGridDB endpoints for each kind of artwork.
+var steamGridDBEndpoints = []string{"grids", "heroes", "logos", "icons"}
+
+// Art style defined by the user, for asset types Steam adds later. Images are
+// saved as <appid><suffix>.<ext> in the grid folder, and overlays are matched
+// by <category><overlay>.<ext>.
+type customArtStyle struct {
+	Name    string
+	Suffix  string
+	Overlay string
+	// File name on the Steam CDN, e.g. "capsule_616x353.jpg". Optional.
+	Steam string
+	// SteamGridDB endpoint: grids, heroes, logos or icons. Optional.
+	SteamGridDB string
+	// SteamGridDB styles and dimensions, comma separated. Optional.
+	Styles     string
+	Dimensions string
+}
+
+// Adds the art styles defined in a JSON file, a list of customArtStyle.
+func addCustomArtStyles(path string, options Options, artStyles map[string][]string) error {
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var customStyles []customArtStyle
+	err = json.Unmarshal(fileBytes, &customStyles)
+	if err != nil {
+		return errors.New("Invalid art styles file " + path + ": " + err.Error())
+	}
+
+	for _, style := range customStyles {
+		if style.Name == "" || style.Suffix == "" || style.Overlay == "" {
+			return errors.New("Art style in " + path + " is missing its name, suffix or overlay")
+		}
+		for name, extensions := range artStyles {
+			if name == style.Name || extensions[0] == style.Suffix {
+				return errors.New("Art style " + style.Name + " conflicts with " + name)
+			}
+		}
+		if style.SteamGridDB != "" && !contains(steamGridDBEndpoints, style.SteamGridDB) {
+			return errors.New("Unknown SteamGridDB endpoint " + style.SteamGridDB + " for art style " + style.Name)
+		}
+		err = validateDimensions(style.Dimensions)
+		if err != nil {
+			return err
+		}
+
+		filter := ""
+		if style.SteamGridDB != "" {
+			styles := style.Styles
+			if styles == "" {
+				styles = options.SteamGridDBStyles
+			}
+			filter = "?styles=" + styles + "&types=" + options.SteamGridDBTypes + "&nsfw=" + options.SteamGridDBNsfw + "&humor=" + options.SteamGridDBHumor
+			if style.Dimensions != "" {
+				filter += "&dimensions=" + style.Dimensions
+			}
+		}
+		artStyles[style.Name] = []string{style.Suffix, style.Overlay, style.Steam, filter, firstDimensions(style.Dimensions), style.SteamGridDB}
+	}
+	return nil
+}
+
+// Sorts art style names with the built-in ones first, in their usual order,
+// and the custom ones after them by name.
+func sortArtStyles(names []string) {
+	rank := func(name string) int {
+		for i, builtin := range builtinArtStyles {
+			if name == builtin {
+				return i
+			}
+		}
+		return len(builtinArtStyles)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if rank(names[i]) != rank(names[j]) {
+			return rank(names[i]) < rank(names[j])
+		}
+		return names[i] < names[j]
+	})
+}
+
+// Returns the total number of games in a report by art style.
+func countGames(gamesByStyle map[string][]*Game) int {
+	n := 0
+	for _, games := range gamesByStyle {
+		n += len(games)
+	}
+	return n
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
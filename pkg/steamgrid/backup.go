@@ -0,0 +1,350 @@
+package steamgrid
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// BackupGame if a game has a custom image, backs it up by appending "(original)" to the
+// file name. Compressed backups get an extra compressedBackupExt extension.
+// Returns the path of the backup, or "" if there was nothing to back up.
+func backupGame(gridDir string, game *Game, artStyleExtensions []string, compress bool) (string, error) {
+	if game.CleanImageBytes == nil {
+		return "", nil
+	}
+	backupPath := getBackupPath(gridDir, game, artStyleExtensions)
+	if !compress {
+		return backupPath, writeFileAtomic(backupPath, game.CleanImageBytes, 0666)
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write(game.CleanImageBytes)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+	backupPath += compressedBackupExt
+	return backupPath, writeFileAtomic(backupPath, compressed.Bytes(), 0666)
+}
+
+// Extension added to compressed backups, after the image extension.
+const compressedBackupExt = ".gz"
+
+// Reads an image file, decompressing it if it's a compressed backup. Returns
+// the image bytes and the image extension.
+func readImageFile(path string) ([]byte, string, error) {
+	fileBytes, err := ioutil.ReadFile(longPath(path))
+	if err != nil {
+		return nil, "", err
+	}
+	if !strings.HasSuffix(path, compressedBackupExt) {
+		return fileBytes, filepath.Ext(path), nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(fileBytes))
+	if err != nil {
+		return nil, "", err
+	}
+	imageBytes, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, "", err
+	}
+	return imageBytes, filepath.Ext(strings.TrimSuffix(path, compressedBackupExt)), nil
+}
+
+// Writes the file through a temporary file in the same directory that is
+// renamed into place, so a crash never leaves a truncated file behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	path = longPath(path)
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+
+	_, err = tempFile.Write(data)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
+
+// Folder with a subfolder of backups per user, see --backup-dir. Empty keeps
+// the backups in the "originals" folder inside each grid folder.
+var backupRoot string
+
+// Steam user ID of each grid folder, naming their backup folders.
+var gridDirUsers = map[string]string{}
+
+// Sets the folder for the backups of all users, or "" for the default.
+func setBackupDir(dir string) error {
+	if dir == "" {
+		backupRoot = ""
+		return nil
+	}
+	absolute, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	backupRoot = absolute
+	return nil
+}
+
+// Returns the folder with the backups of the images in the grid folder.
+func originalsDir(gridDir string) string {
+	if backupRoot == "" {
+		return filepath.Join(gridDir, "originals")
+	}
+	userID, ok := gridDirUsers[gridDir]
+	if !ok {
+		// userdata/<id>/config/grid
+		userID = filepath.Base(filepath.Dir(filepath.Dir(gridDir)))
+	}
+	return filepath.Join(backupRoot, userID)
+}
+
+// Moves the backups in the "originals" folder of the grid folder to the
+// --backup-dir folder, so the images keep their clean copies. Backups already
+// in the new folder win.
+func migrateBackups(gridDir string) {
+	if backupRoot == "" {
+		return
+	}
+	oldDir := filepath.Join(gridDir, "originals")
+	files, err := ioutil.ReadDir(oldDir)
+	if err != nil || len(files) == 0 {
+		return
+	}
+	newDir := originalsDir(gridDir)
+	err = os.MkdirAll(newDir, 0777)
+	if err != nil {
+		fmt.Printf("Failed to move the backups to %v: %v\n", newDir, err.Error())
+		return
+	}
+
+	moved := 0
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		oldPath := filepath.Join(oldDir, file.Name())
+		newPath := filepath.Join(newDir, file.Name())
+		if _, err := os.Stat(longPath(newPath)); err == nil {
+			continue
+		}
+		err := os.Rename(longPath(oldPath), longPath(newPath))
+		if err != nil {
+			// On another drive.
+			var fileBytes []byte
+			fileBytes, err = ioutil.ReadFile(longPath(oldPath))
+			if err == nil {
+				err = writeFileAtomic(newPath, fileBytes, 0666)
+			}
+			if err == nil {
+				err = os.Remove(longPath(oldPath))
+			}
+		}
+		if err != nil {
+			fmt.Printf("Failed to move the backup %v: %v\n", oldPath, err.Error())
+			continue
+		}
+		moved++
+	}
+	// Only removed if everything was moved.
+	os.Remove(longPath(oldDir))
+	if moved > 0 {
+		fmt.Printf("Moved %v backups from %v to %v.\n", moved, oldDir, newDir)
+	}
+}
+
+func getBackupPath(gridDir string, game *Game, artStyleExtensions []string) string {
+	return filepath.Join(originalsDir(gridDir), game.ID+artStyleExtensions[0]+" "+imageHash(game.OverlayImageBytes)+game.ImageExt)
+}
+
+// Returns the hex encoded sha256 hash of the image.
+func imageHash(imageBytes []byte) string {
+	hash := sha256.Sum256(imageBytes)
+	// [:] is required to convert a fixed length byte array to a byte slice.
+	return hex.EncodeToString(hash[:])
+}
+
+// Returns true if the grid dir has an image for the game's art style.
+func hasGridImage(gridDir string, gameID string, artStyleExtensions []string) bool {
+	images, _ := filepath.Glob(filepath.Join(gridDir, gameID+artStyleExtensions[0]+".*"))
+	return len(filterForImages(images)) > 0
+}
+
+// Removes the grid images of the game's art style and, unless keepBackups is
+// set, their backups. With --no-backup no new backup replaces them, so the
+// old ones are kept, and the removed images aren't retained for --rollback.
+func removeExisting(gridDir string, gameID string, artStyleExtensions []string, keepBackups bool) error {
+	images, err := filepath.Glob(filepath.Join(gridDir, gameID+artStyleExtensions[0]+".*"))
+	if err != nil {
+		return err
+	}
+	images = filterForImages(images)
+	if !keepBackups {
+		for _, path := range images {
+			retainRemoved(gridDir, path, false)
+		}
+	}
+
+	if !keepBackups {
+		backups, err := filepath.Glob(filepath.Join(originalsDir(gridDir), gameID+artStyleExtensions[0]+" *.*"))
+		if err != nil {
+			return err
+		}
+		backups = filterForImages(backups)
+		for _, path := range backups {
+			retainRemoved(gridDir, path, true)
+		}
+		images = append(images, backups...)
+	}
+
+	for _, path := range images {
+		err = os.Remove(longPath(path))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Source of images found in the grid directory without a matching backup,
+// meaning they were not set by steamgrid.
+const manualCustomizationSource = "manual customization"
+
+func loadImage(game *Game, sourceName string, imagePath string) error {
+	imageBytes, ext, err := readImageFile(imagePath)
+	if err == nil {
+		game.ImageExt = ext
+		game.CleanImageBytes = imageBytes
+		game.ImageSource = sourceName
+	}
+	return err
+}
+
+// https://wenzr.wordpress.com/2018/04/09/go-glob-case-insensitive/
+func insensitiveFilepath(path string) string {
+	if runtime.GOOS == "windows" {
+		return path
+	}
+
+	p := ""
+	for _, r := range path {
+		if unicode.IsLetter(r) {
+			p += fmt.Sprintf("[%c%c]", unicode.ToLower(r), unicode.ToUpper(r))
+		} else {
+			p += string(r)
+		}
+	}
+	return p
+}
+
+func filterForImages(paths []string) []string {
+	var matchedPaths []string
+	for _, path := range paths {
+		ext := filepath.Ext(strings.TrimSuffix(path, compressedBackupExt))
+		switch ext {
+		case ".png":
+			matchedPaths = append(matchedPaths, path)
+		case ".jpg":
+			matchedPaths = append(matchedPaths, path)
+		case ".jpeg":
+			matchedPaths = append(matchedPaths, path)
+		case ".gif":
+			matchedPaths = append(matchedPaths, path)
+		case ".webp":
+			matchedPaths = append(matchedPaths, path)
+		}
+	}
+	return matchedPaths
+}
+
+// Loads the backup of the image recorded in the manifest entry, if it's
+// still there.
+func loadManifestBackup(gridDir string, game *Game, artStyleExtensions []string, entry *ManifestEntry) {
+	backups, _ := filepath.Glob(filepath.Join(originalsDir(gridDir), game.ID+artStyleExtensions[0]+" "+entry.Hash+".*"))
+	backups = filterForImages(backups)
+	if len(backups) > 0 {
+		loadImage(game, "backup", backups[0])
+	}
+}
+
+func loadExisting(overridePath string, gridDir string, game *Game, artStyleExtensions []string) {
+	overridenIDs, _ := filepath.Glob(filepath.Join(overridePath, game.ID+artStyleExtensions[0]+".*"))
+	if overridenIDs != nil && len(overridenIDs) > 0 {
+		loadImage(game, "local file in directory 'games'", overridenIDs[0])
+		return
+	}
+	overridenIDs = filterForImages(overridenIDs)
+
+	if game.Name != "" {
+		re := regexp.MustCompile(`\W+`)
+		globName := re.ReplaceAllString(game.Name, "*")
+		overridenNames, _ := filepath.Glob(filepath.Join(overridePath, insensitiveFilepath(globName)+artStyleExtensions[1]+".*"))
+		if overridenNames != nil && len(overridenNames) > 0 {
+			loadImage(game, "local file in directory games/", overridenNames[0])
+			return
+		}
+	}
+
+	// If there are any old-style backups (without hash), load them over the existing (with overlay) images.
+	oldBackups, err := filepath.Glob(filepath.Join(gridDir, game.ID+artStyleExtensions[0]+" (original)*"))
+	if err == nil && len(oldBackups) > 0 {
+		err = loadImage(game, "legacy backup (now converted)", oldBackups[0])
+		if err == nil {
+			os.Remove(longPath(oldBackups[0]))
+			return
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(gridDir, game.ID+artStyleExtensions[0]+".*"))
+	files = filterForImages(files)
+	if err == nil && len(files) > 0 {
+		err = loadImage(game, manualCustomizationSource, files[0])
+		if err == nil {
+			// set as overlay to check for hash in getBackupPath()
+			game.OverlayImageBytes = game.CleanImageBytes
+
+			// See if there exists a backup image with no overlays or modifications.
+			// The backup may have a different extension if the image was converted.
+			backupPath := getBackupPath(gridDir, game, artStyleExtensions)
+			backups, _ := filepath.Glob(strings.TrimSuffix(backupPath, game.ImageExt) + ".*")
+			if len(backups) > 0 {
+				loadImage(game, "backup", backups[0])
+			}
+
+			// remove overlay
+			game.OverlayImageBytes = nil
+		}
+	}
+
+}
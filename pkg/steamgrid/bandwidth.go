@@ -0,0 +1,111 @@
+package steamgrid
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token bucket shared by all downloads, so the total rate stays under the
+// limit no matter how many requests run in parallel.
+type tokenBucket struct {
+	mutex sync.Mutex
+	// Bytes per second, also the maximum burst.
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// Blocks until n bytes can be consumed.
+func (bucket *tokenBucket) wait(n int) {
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+
+	now := time.Now()
+	bucket.tokens += now.Sub(bucket.last).Seconds() * bucket.rate
+	if bucket.tokens > bucket.rate {
+		bucket.tokens = bucket.rate
+	}
+	bucket.last = now
+
+	bucket.tokens -= float64(n)
+	if bucket.tokens < 0 {
+		// Holding the lock while sleeping makes the other downloads wait too.
+		time.Sleep(time.Duration(-bucket.tokens / bucket.rate * float64(time.Second)))
+		bucket.tokens = 0
+		bucket.last = time.Now()
+	}
+}
+
+// Bandwidth limit for all downloads, nil if unlimited.
+var bandwidthLimit *tokenBucket
+
+// Reads in small chunks so the limit is applied smoothly.
+const limitedReadSize = 16 * 1024
+
+type limitedReader struct {
+	io.ReadCloser
+	bucket *tokenBucket
+}
+
+func (reader limitedReader) Read(p []byte) (int, error) {
+	if len(p) > limitedReadSize {
+		p = p[:limitedReadSize]
+	}
+	n, err := reader.ReadCloser.Read(p)
+	reader.bucket.wait(n)
+	return n, err
+}
+
+// Wraps response bodies with the bandwidth limit, if there is one.
+type limitedTransport struct {
+	transport http.RoundTripper
+}
+
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	response, err := t.transport.RoundTrip(req)
+	if err == nil && bandwidthLimit != nil {
+		response.Body = limitedReader{response.Body, bandwidthLimit}
+	}
+	return response, err
+}
+
+// Limits all downloads to the given rate, like "5MB/s", "500KB/s" or "1.5M".
+// An empty string removes the limit.
+func setMaxBandwidth(limit string) error {
+	if limit == "" {
+		bandwidthLimit = nil
+		return nil
+	}
+
+	rate, err := parseByteSize(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(limit)), "/S"))
+	if err != nil || rate <= 0 {
+		return errors.New("Invalid bandwidth limit " + limit + ", expected something like 5MB/s")
+	}
+
+	bandwidthLimit = &tokenBucket{rate: rate, tokens: rate, last: time.Now()}
+	return nil
+}
+
+// Parses a number of bytes with an optional K, M or G suffix, like "1.5M" or
+// "2GB".
+func parseByteSize(size string) (float64, error) {
+	text := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(size)), "B")
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(text, "K"):
+		multiplier = 1e3
+	case strings.HasSuffix(text, "M"):
+		multiplier = 1e6
+	case strings.HasSuffix(text, "G"):
+		multiplier = 1e9
+	}
+	text = strings.TrimRight(text, "KMG")
+
+	value, err := strconv.ParseFloat(text, 64)
+	return value * multiplier, err
+}
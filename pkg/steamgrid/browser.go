@@ -0,0 +1,280 @@
+package steamgrid
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Candidate image for a game, as shown by the interactive interfaces.
+type artworkCandidate struct {
+	Provider string
+	URL      string
+	Thumb    string
+}
+
+// Games of all users and their artwork, shared by the web and terminal
+// interfaces used to review and pick artwork by hand.
+type artworkBrowser struct {
+	options   Options
+	artStyles map[string][]string
+	games     map[string]*Game
+	// Grid dirs of the users owning each game.
+	gridDirs map[string][]string
+}
+
+// Loads the games of all users.
+func newArtworkBrowser(options Options) (*artworkBrowser, error) {
+	users, err := LoadUsers(options)
+	if err != nil {
+		return nil, err
+	}
+
+	artStyles, err := getArtStyles(options)
+	if err != nil {
+		return nil, err
+	}
+	browser := &artworkBrowser{
+		options:   options,
+		artStyles: artStyles,
+		games:     map[string]*Game{},
+		gridDirs:  map[string][]string{},
+	}
+	for _, user := range users {
+		gridDir := user.GridDir
+		games := GetGames(user, options.NonSteamOnly, options.AppIDs)
+		if options.SkipHidden {
+			removeHiddenGames(games)
+		}
+		for id, game := range games {
+			if _, ok := browser.games[id]; !ok {
+				if game.Name == "" && !game.Custom {
+					game.Name = appListName(id, true)
+				}
+				browser.games[id] = game
+			}
+			browser.gridDirs[id] = append(browser.gridDirs[id], gridDir)
+		}
+	}
+	return browser, nil
+}
+
+// Returns the games sorted by name.
+func (browser *artworkBrowser) sortedGames() []*Game {
+	var games []*Game
+	for _, game := range browser.games {
+		games = append(games, game)
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return strings.ToLower(games[i].Name) < strings.ToLower(games[j].Name)
+	})
+	return games
+}
+
+// Returns the enabled art styles in display order.
+func (browser *artworkBrowser) sortedArtStyles() []string {
+	var artStyles []string
+	for artStyle := range browser.artStyles {
+		artStyles = append(artStyles, artStyle)
+	}
+	sortArtStyles(artStyles)
+	return artStyles
+}
+
+// Searches all providers for candidates, with thumbnails where available.
+func (browser *artworkBrowser) candidates(game *Game, artStyle string) []artworkCandidate {
+	artStyleExtensions := browser.artStyles[artStyle]
+	var candidates []artworkCandidate
+	for _, provider := range getImageProviders(browser.options) {
+		if p, ok := provider.(steamGridDBProvider); ok {
+			// Show more than just the best SteamGridDB image.
+			images, _, err := getSteamGridDBImages(p.client, game, artStyleExtensions, p.apiKey, 10)
+			if err == nil {
+				for _, image := range images {
+					thumb := image.Thumb
+					if thumb == "" {
+						thumb = image.URL
+					}
+					candidates = append(candidates, artworkCandidate{provider.Name(), image.URL, thumb})
+				}
+			}
+			continue
+		}
+
+		urls, err := provider.Search(game, artStyle, artStyleExtensions)
+		if err != nil {
+			continue
+		}
+		for _, url := range urls {
+			candidates = append(candidates, artworkCandidate{provider.Name(), url, url})
+		}
+	}
+	return candidates
+}
+
+// Returns the path of the current grid image of the first user owning the
+// game, or "" if there is none.
+func (browser *artworkBrowser) currentImagePath(game *Game, artStyle string) string {
+	for _, gridDir := range browser.gridDirs[game.ID] {
+		files, _ := filepath.Glob(filepath.Join(gridDir, game.ID+browser.artStyles[artStyle][0]+".*"))
+		files = filterForImages(files)
+		if len(files) > 0 {
+			return files[0]
+		}
+	}
+	return ""
+}
+
+// Downloads the picked image, stores it as an override in the 'games' folder
+// so future runs keep it, and installs it for all users owning the game.
+func (browser *artworkBrowser) pick(game *Game, artStyle string, url string) error {
+	artStyleExtensions := browser.artStyles[artStyle]
+	response, err := tryDownload(httpClient, url)
+	if err != nil {
+		return err
+	} else if response == nil {
+		return errors.New("Image not found")
+	}
+	imageBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	_, format, err := image.DecodeConfig(bytes.NewBuffer(imageBytes))
+	if err != nil {
+		return err
+	}
+	ext := "." + format
+	if ext == ".jpeg" {
+		// The new library ignores .jpeg
+		ext = ".jpg"
+	}
+
+	err = os.MkdirAll(browser.options.OverridesDir, 0777)
+	if err != nil {
+		return err
+	}
+	// Replace any previous override with a different extension.
+	overrides, _ := filepath.Glob(filepath.Join(browser.options.OverridesDir, game.ID+artStyleExtensions[0]+".*"))
+	for _, override := range filterForImages(overrides) {
+		os.Remove(override)
+	}
+	err = writeFileAtomic(filepath.Join(browser.options.OverridesDir, game.ID+artStyleExtensions[0]+ext), imageBytes, 0666)
+	if err != nil {
+		return err
+	}
+
+	picked := *game
+	picked.ImageSource = "local file in directory 'games'"
+	picked.ImageExt = ext
+	picked.CleanImageBytes = imageBytes
+	picked.OverlayImageBytes = imageBytes
+	for _, gridDir := range browser.gridDirs[game.ID] {
+		err = os.MkdirAll(longPath(gridDir), 0777)
+		if err == nil && !browser.options.NoBackup {
+			err = os.MkdirAll(longPath(originalsDir(gridDir)), 0777)
+		}
+		if err != nil {
+			return err
+		}
+		manifest := LoadManifest(gridDir)
+		err = snapshotReplacedImage(gridDir, manifest, game, artStyleExtensions, !browser.options.NoBackup)
+		if err != nil {
+			return err
+		}
+
+		err = removeExisting(gridDir, game.ID, artStyleExtensions, browser.options.NoBackup)
+		if err != nil {
+			return err
+		}
+		backupName := ""
+		if !browser.options.NoBackup {
+			backupPath, err := backupGame(gridDir, &picked, artStyleExtensions, browser.options.CompressBackups)
+			if err != nil {
+				return err
+			}
+			backupName = filepath.Base(backupPath)
+		}
+		err = writeFileAtomic(filepath.Join(gridDir, game.ID+artStyleExtensions[0]+ext), imageBytes, 0666)
+		if err != nil {
+			return err
+		}
+		var copies []string
+		if !browser.options.NoLegacy {
+			copies = copyKeys(game, artStyleExtensions)
+		}
+		manifest.Set(game.ID, artStyleExtensions, &ManifestEntry{
+			Source:    picked.ImageSource,
+			ImageExt:  ext,
+			Hash:      imageHash(imageBytes),
+			CleanHash: imageHash(imageBytes),
+			Backup:    backupName,
+			Copies:    copies,
+			Time:      time.Now(),
+		})
+		err = manifest.Save()
+		if err != nil {
+			return err
+		}
+
+		for _, id := range alternateGridIDs(game) {
+			err = removeExisting(gridDir, id, artStyleExtensions, browser.options.NoBackup)
+			if err != nil {
+				return err
+			}
+			if browser.options.NoLegacy {
+				continue
+			}
+			err = writeFileAtomic(filepath.Join(gridDir, id+artStyleExtensions[0]+ext), imageBytes, 0666)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Saves a run snapshot with the image about to be replaced by a pick, so
+// --rollback can put it back unless retainImages is false. Images set by hand aren't in the manifest yet,
+// so they're added to the snapshot first.
+func snapshotReplacedImage(gridDir string, manifest *Manifest, game *Game, artStyleExtensions []string, retainImages bool) error {
+	snapshot := &Manifest{manifest.path, map[string]*ManifestEntry{}}
+	for key, entry := range manifest.Entries {
+		snapshot.Entries[key] = entry
+	}
+	if snapshot.Get(game.ID, artStyleExtensions) == nil {
+		files, _ := filepath.Glob(filepath.Join(gridDir, game.ID+artStyleExtensions[0]+".*"))
+		files = filterForImages(files)
+		if len(files) == 0 {
+			return nil
+		}
+		imageBytes, ext, err := readImageFile(files[0])
+		if err != nil {
+			return err
+		}
+		// The copies get the image too when rolling back, instead of
+		// keeping the picked one.
+		var copies []string
+		for _, id := range alternateGridIDs(game) {
+			if hasGridImage(gridDir, id, artStyleExtensions) {
+				copies = append(copies, id+artStyleExtensions[0])
+			}
+		}
+		snapshot.Set(game.ID, artStyleExtensions, &ManifestEntry{
+			Source:    manualCustomizationSource,
+			ImageExt:  ext,
+			Hash:      imageHash(imageBytes),
+			CleanHash: imageHash(imageBytes),
+			Copies:    copies,
+			Time:      time.Now(),
+		})
+	}
+	return snapshot.SaveSnapshot(time.Now(), retainImages)
+}
@@ -0,0 +1,87 @@
+package steamgrid
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Region, revision and dump tags in ROM names: "(U)", "(Europe)", "[!]", "(Rev 1)".
+var romTagsPattern = regexp.MustCompile(`\s*[\(\[\{][^\)\]\}]*[\)\]\}]`)
+
+// Extensions seen in shortcut names pointing directly at ROM files.
+var romExtensionPattern = regexp.MustCompile(`(?i)\.(sfc|smc|nes|gb|gbc|gba|nds|3ds|n64|z64|v64|md|gen|smd|sms|gg|pce|iso|cue|bin|chd|cso|pbp|zip|7z|exe|lnk|bat|sh|url)$`)
+
+// Bracketed region codes, e.g. "(U)", "(Europe)" or "[JPN]".
+const regionCodes = `(u|e|j|w|usa|us|eur|europe|jp|jpn|japan|pal|ntsc|world|uk|de|fr|es|it|nl|sv|br|kr|korea|ch|china|au|australia|germany|france|spain|italy|asia)`
+
+var regionTagPattern = regexp.MustCompile(`(?i)\s*[\(\[]` + regionCodes + `(,\s*` + regionCodes + `)*[\)\]]`)
+
+// Trademark and copyright symbols.
+var trademarkPattern = regexp.MustCompile(`[™®©]|\((tm|r|c)\)`)
+
+// Steps to clean shortcut names before searching for them, in the order they
+// are applied.
+var nameCleaners = []struct {
+	name  string
+	clean func(string) string
+}{
+	{"extension", func(name string) string {
+		if strings.ContainsAny(name, `/\`) {
+			name = filepath.Base(filepath.ToSlash(strings.Replace(name, `\`, "/", -1)))
+		}
+		return romExtensionPattern.ReplaceAllString(name, "")
+	}},
+	{"region", func(name string) string { return regionTagPattern.ReplaceAllString(name, "") }},
+	{"tags", func(name string) string { return romTagsPattern.ReplaceAllString(name, "") }},
+	{"trademark", func(name string) string { return trademarkPattern.ReplaceAllString(name, "") }},
+}
+
+// Default cleaning steps for shortcut names.
+const DefaultNameCleaners = "extension,region,tags,trademark"
+
+// Checks a comma separated list of cleaning steps, or "none".
+func validateNameCleaners(steps string) error {
+	if steps == "none" || steps == "" {
+		return nil
+	}
+	for _, step := range strings.Split(steps, ",") {
+		found := false
+		for _, cleaner := range nameCleaners {
+			found = found || cleaner.name == strings.TrimSpace(step)
+		}
+		if !found {
+			return errors.New("Unknown name cleaning step " + step + ", must be one of " + DefaultNameCleaners + " or none")
+		}
+	}
+	return nil
+}
+
+// Applies the comma separated cleaning steps to a shortcut name, e.g.
+// "roms/snes/Chrono Trigger (U) [!].sfc" becomes "Chrono Trigger" and
+// "The Witcher 3 (GOG) [modded]" becomes "The Witcher 3".
+func cleanName(name string, steps string) string {
+	enabled := map[string]bool{}
+	for _, step := range strings.Split(steps, ",") {
+		enabled[strings.TrimSpace(step)] = true
+	}
+
+	cleaned := name
+	for _, cleaner := range nameCleaners {
+		if enabled[cleaner.name] {
+			cleaned = cleaner.clean(cleaned)
+		}
+	}
+	cleaned = strings.Join(strings.Fields(strings.Replace(cleaned, "_", " ", -1)), " ")
+	if cleaned == "" {
+		// Never clean a name away entirely.
+		return name
+	}
+	return cleaned
+}
+
+// Cleans a ROM file name to a searchable game name with all steps.
+func cleanRomName(name string) string {
+	return cleanName(name, DefaultNameCleaners)
+}
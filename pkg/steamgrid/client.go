@@ -0,0 +1,80 @@
+package steamgrid
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Transport shared by all HTTP clients, so connections are pooled and kept
+// alive between requests instead of being torn down after every image.
+var sharedTransport = &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   16,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 10 * time.Second,
+}
+
+// Default timeouts for whole requests (connecting, headers and body) of each
+// provider.
+var defaultProviderTimeouts = map[string]time.Duration{
+	"steam":         30 * time.Second,
+	"steamgriddb":   30 * time.Second,
+	"igdb":          30 * time.Second,
+	"screenscraper": 30 * time.Second,
+	"stores":        30 * time.Second,
+	"search":        20 * time.Second,
+}
+
+// Timeouts of the current run. setTimeouts replaces the map, so the defaults
+// are never changed.
+var providerTimeouts = defaultProviderTimeouts
+
+// Timeout for image downloads, which may be large animations.
+const downloadTimeout = 2 * time.Minute
+
+// Returns a client using the shared transport and the bandwidth limit.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: limitedTransport{sharedTransport}, Timeout: timeout}
+}
+
+// Client for image downloads and everything outside of the providers.
+var httpClient = newHTTPClient(downloadTimeout)
+
+// Sets the timeouts from a comma separated list of name=duration, where the
+// name is a provider or "download", e.g. "search=5s,download=1m". The others
+// get their defaults.
+func setTimeouts(list string) error {
+	providerTimeouts = map[string]time.Duration{}
+	for name, timeout := range defaultProviderTimeouts {
+		providerTimeouts[name] = timeout
+	}
+	httpClient.Timeout = downloadTimeout
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if _, ok := providerTimeouts[name]; !ok && name != "download" {
+			return errors.New("Unknown timeout " + name + ", must be one of steam, steamgriddb, igdb, screenscraper, stores, search or download")
+		}
+		if len(parts) != 2 {
+			return errors.New("Missing duration for timeout " + name + ", e.g. " + name + "=30s")
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || timeout <= 0 {
+			return errors.New("Invalid timeout " + item + ", e.g. " + name + "=30s")
+		}
+		if name == "download" {
+			httpClient.Timeout = timeout
+		} else {
+			providerTimeouts[name] = timeout
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package steamgrid
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Steam Cloud touches the remotecache.vdf files while syncing, and may revert
+// grid images written during the sync. A sync is considered over after the
+// files have been quiet for this long.
+const cloudSyncQuietPeriod = 15 * time.Second
+
+// Longest time to wait for a sync to finish before writing anyway.
+const cloudSyncMaxWait = 3 * time.Minute
+
+// Returns true if Steam is running and has written to the user's Steam Cloud
+// caches recently, meaning a sync is probably in progress.
+func isCloudSyncing(user User) bool {
+	if !isSteamRunning() {
+		return false
+	}
+	caches, err := filepath.Glob(filepath.Join(user.Dir, "*", "remotecache.vdf"))
+	if err != nil {
+		return false
+	}
+	for _, cache := range caches {
+		info, err := os.Stat(cache)
+		if err == nil && time.Since(info.ModTime()) < cloudSyncQuietPeriod {
+			return true
+		}
+	}
+	return false
+}
+
+// Blocks while Steam Cloud is syncing the user's files, up to cloudSyncMaxWait.
+func waitForCloudSync(user User) {
+	if !isCloudSyncing(user) {
+		return
+	}
+	fmt.Println("Steam Cloud is syncing, waiting for it to finish before writing images...")
+	start := time.Now()
+	for isCloudSyncing(user) {
+		if time.Since(start) > cloudSyncMaxWait {
+			fmt.Println("Warning: Steam Cloud is still syncing, writing images anyway. Some may be reverted.")
+			return
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// Returns the grid images written by the last run that are now different,
+// usually because Steam Cloud reverted them. Missing images are not included.
+func revertedImages(gridDir string, manifest *Manifest) []string {
+	var reverted []string
+	for key, entry := range manifest.Entries {
+		path := filepath.Join(gridDir, key+entry.ImageExt)
+		imageBytes, _, err := readImageFile(path)
+		if err == nil && imageHash(imageBytes) != entry.Hash {
+			reverted = append(reverted, path)
+		}
+	}
+	return reverted
+}
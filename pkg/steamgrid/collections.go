@@ -0,0 +1,123 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Prefix of the keys of library collections in the cloud storage.
+const collectionKeyPrefix = "user-collections."
+
+// Tags for Steam's built-in collections, which have no name.
+var builtinCollectionTags = map[string]string{
+	"favorite": "favorite",
+	"hidden":   "hidden",
+}
+
+// Collection created in the new library UI. Dynamic collections only have a
+// filter and no list of games, so they are ignored.
+type libraryCollection struct {
+	ID      string
+	Name    string
+	Added   []int64
+	Removed []int64
+}
+
+// Loads the collections of the new library UI, which are stored in
+// config/cloudstorage/cloud-storage-namespace-1.json as a list of
+// [key, entry] pairs, the value of each entry being the collection as JSON.
+func loadCollections(user User) []libraryCollection {
+	files, _ := filepath.Glob(filepath.Join(findInsensitive(user.Dir, "config"), "cloudstorage", "cloud-storage-namespace-*"))
+	var collections []libraryCollection
+	for _, file := range files {
+		fileBytes, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var pairs [][]json.RawMessage
+		if json.Unmarshal(fileBytes, &pairs) != nil {
+			continue
+		}
+
+		for _, pair := range pairs {
+			if len(pair) != 2 {
+				continue
+			}
+			var entry struct {
+				Key       string
+				Value     string
+				IsDeleted bool `json:"is_deleted"`
+			}
+			if json.Unmarshal(pair[1], &entry) != nil || entry.IsDeleted || !strings.HasPrefix(entry.Key, collectionKeyPrefix) {
+				continue
+			}
+			var collection libraryCollection
+			if json.Unmarshal([]byte(entry.Value), &collection) != nil {
+				continue
+			}
+			collections = append(collections, collection)
+		}
+	}
+	return collections
+}
+
+// Adds the collections of the new library UI as tags, like the categories
+// from sharedconfig.vdf. Games not in the list yet are added without a name
+// if addMissing is set.
+func addCollections(user User, games map[string]*Game, addMissing bool) {
+	for _, collection := range loadCollections(user) {
+		tag := collection.Name
+		if builtinTag, ok := builtinCollectionTags[collection.ID]; ok {
+			tag = builtinTag
+		}
+		if tag == "" {
+			continue
+		}
+
+		removed := map[int64]bool{}
+		for _, appID := range collection.Removed {
+			removed[appID] = true
+		}
+		for _, appID := range collection.Added {
+			if removed[appID] {
+				continue
+			}
+			// Shortcut IDs are sometimes stored as negative numbers.
+			gameID := fmt.Sprint(uint32(appID))
+
+			game, ok := games[gameID]
+			if !ok {
+				if !addMissing {
+					continue
+				}
+				game = &Game{gameID, "", []string{}, "", nil, nil, "", false, 0, ""}
+				games[gameID] = game
+			}
+			if !hasTag(game, tag) {
+				game.Tags = append(game.Tags, tag)
+			}
+		}
+	}
+}
+
+// Returns true if the game already has the tag, ignoring case.
+func hasTag(game *Game, tag string) bool {
+	for _, existing := range game.Tags {
+		if strings.EqualFold(existing, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Removes the games in Steam's "hidden" collection.
+func removeHiddenGames(games map[string]*Game) {
+	for id, game := range games {
+		if hasTag(game, builtinCollectionTags["hidden"]) {
+			delete(games, id)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package steamgrid
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Sets the package state that all commands read from the options: the
+// backup folder and the games of the RetroArch playlists.
+func setupOptions(options Options) error {
+	err := setBackupDir(options.BackupDir)
+	if err != nil {
+		return err
+	}
+	return loadPlaylists(options.RetroArchPlaylists)
+}
+
+// LoadUsers returns the users of the Steam installation in the options,
+// after setting up the backup folder and RetroArch playlists they give.
+func LoadUsers(options Options) ([]User, error) {
+	err := setupOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	installationDir, err := GetSteamInstallation(options.SteamDir)
+	if err != nil {
+		return nil, err
+	}
+	return GetUsers(installationDir)
+}
+
+// PrintUsers lists the Steam users and their grid folders.
+func PrintUsers(options Options) error {
+	users, err := LoadUsers(options)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		images, _ := filepath.Glob(filepath.Join(user.GridDir, "*.*"))
+		fmt.Printf("%v (%v): %v images in %v\n", user.Name, user.SteamID32, len(filterForImages(images)), user.GridDir)
+	}
+	return nil
+}
+
+// Verify checks the grid images and backups of all users, removing corrupt
+// ones.
+func Verify(options Options) error {
+	users, err := LoadUsers(options)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		removed, err := verifyGridDir(user.GridDir)
+		if err != nil {
+			return err
+		}
+		removedBackups, err := verifyBackups(user.GridDir, LoadManifest(user.GridDir))
+		if err != nil {
+			return err
+		}
+		for _, path := range append(removed, removedBackups...) {
+			fmt.Printf("Removed %v\n", path)
+		}
+		fmt.Printf("%v: %v corrupt or modified files removed.\n", user.Name, len(removed)+len(removedBackups))
+	}
+	return nil
+}
+
+// Restore writes the clean backups over the images steamgrid wrote, and
+// forgets them in the manifest so the next run treats them as set by hand.
+func Restore(options Options) error {
+	users, err := LoadUsers(options)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		manifest := LoadManifest(user.GridDir)
+		restored := 0
+		for key, entry := range manifest.Entries {
+			if entry.Backup == "" {
+				continue
+			}
+			imageBytes, ext, err := readImageFile(filepath.Join(originalsDir(user.GridDir), entry.Backup))
+			if err != nil {
+				fmt.Printf("Skipping %v: %v\n", key, err.Error())
+				continue
+			}
+			if !options.NoBackup {
+				retainRemoved(user.GridDir, filepath.Join(user.GridDir, key+entry.ImageExt), false)
+			}
+			os.Remove(longPath(filepath.Join(user.GridDir, key+entry.ImageExt)))
+			err = writeFileAtomic(filepath.Join(user.GridDir, key+ext), imageBytes, 0666)
+			if err != nil {
+				return err
+			}
+			// Older clients and Big Picture mode show the copies.
+			for _, copyKey := range entry.Copies {
+				err = removeGridImages(user.GridDir, copyKey)
+				if err == nil {
+					err = writeFileAtomic(filepath.Join(user.GridDir, copyKey+ext), imageBytes, 0666)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			delete(manifest.Entries, key)
+			restored++
+		}
+		err = manifest.Save()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%v: %v images restored.\n", user.Name, restored)
+	}
+	return nil
+}
+
+// Export saves the grid images of all users to a zip file.
+func Export(options Options, path string) error {
+	users, err := LoadUsers(options)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	exported := 0
+	for _, user := range users {
+		images, _ := filepath.Glob(filepath.Join(user.GridDir, "*.*"))
+		for _, path := range filterForImages(images) {
+			imageBytes, err := ioutil.ReadFile(longPath(path))
+			if err != nil {
+				return err
+			}
+			writer, err := archive.Create(user.SteamID32 + "/" + filepath.Base(path))
+			if err != nil {
+				return err
+			}
+			_, err = writer.Write(imageBytes)
+			if err != nil {
+				return err
+			}
+			exported++
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %v images to %v.\n", exported, path)
+	return nil
+}
@@ -0,0 +1,41 @@
+package steamgrid
+
+import (
+	"fmt"
+	"net/http"
+	// Registers the /debug/pprof handlers on the default mux.
+	_ "net/http/pprof"
+	"os"
+	"runtime/trace"
+)
+
+// Starts the --pprof server and the --trace recording, if enabled. Returns a
+// function that stops the trace, to be called when the run ends.
+func startDebugging(pprofAddr string, traceFile string) (func(), error) {
+	if pprofAddr != "" {
+		fmt.Printf("Serving pprof at http://%v/debug/pprof/\n", pprofAddr)
+		go func() {
+			err := http.ListenAndServe(pprofAddr, nil)
+			if err != nil {
+				fmt.Printf("pprof server stopped: %v\n", err.Error())
+			}
+		}()
+	}
+
+	if traceFile == "" {
+		return func() {}, nil
+	}
+	file, err := os.Create(traceFile)
+	if err != nil {
+		return nil, err
+	}
+	err = trace.Start(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		trace.Stop()
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,77 @@
+package steamgrid
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Modes for linking identical images. Symlinks aren't supported: rewriting
+// the first image, e.g. for another user's overlays, would change all the
+// images linking to it.
+var dedupModes = []string{"off", "hardlink"}
+
+// Hardlinks identical images written during a run, e.g. the same game for
+// several users or the legacy Big Picture copies, instead of storing them
+// many times. Images are always replaced with writeFileAtomic, never edited
+// in place, so rewriting one of them breaks its link and leaves the others
+// as they were.
+type deduplicator struct {
+	mode string
+	// Hash -> first path written with that content.
+	paths map[string]string
+	// Bytes not written thanks to links.
+	saved int
+}
+
+func newDeduplicator(mode string) (*deduplicator, error) {
+	for _, m := range dedupModes {
+		if m == mode {
+			return &deduplicator{mode, map[string]string{}, 0}, nil
+		}
+	}
+	return nil, errors.New("Unknown dedup mode " + mode + ", must be one of off or hardlink")
+}
+
+// Writes the image to path, linking it to an identical image written before
+// if possible.
+func (d *deduplicator) write(path string, imageBytes []byte) error {
+	if d.mode == "off" {
+		return writeFileAtomic(path, imageBytes, 0666)
+	}
+
+	hash := imageHash(imageBytes)
+	if existing, ok := d.paths[hash]; ok && existing != path {
+		// Links may be unsupported by the file system, e.g. FAT, so fall
+		// back to a regular write.
+		if linkAtomic(existing, path) == nil {
+			d.saved += len(imageBytes)
+			return nil
+		}
+	}
+
+	err := writeFileAtomic(path, imageBytes, 0666)
+	if err == nil {
+		d.paths[hash] = path
+	}
+	return err
+}
+
+// Creates a hardlink to target at path, replacing whatever is there.
+func linkAtomic(target string, path string) error {
+	target = longPath(target)
+	path = longPath(path)
+	tempPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".link.tmp")
+	os.Remove(tempPath)
+
+	err := os.Link(target, tempPath)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tempPath, path)
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package steamgrid
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A difference between the manifest entries of two runs. Old is nil for
+// added images and new is nil for removed ones.
+type manifestChange struct {
+	key string
+	old *ManifestEntry
+	new *ManifestEntry
+}
+
+// Returns the entries that were added, removed, or changed source or image
+// between the two manifests, sorted by key.
+func diffManifests(previous map[string]*ManifestEntry, current map[string]*ManifestEntry) []manifestChange {
+	var changes []manifestChange
+	for key, entry := range current {
+		old, ok := previous[key]
+		if !ok || old.Source != entry.Source || old.Hash != entry.Hash {
+			changes = append(changes, manifestChange{key, old, entry})
+		}
+	}
+	for key, old := range previous {
+		if _, ok := current[key]; !ok {
+			changes = append(changes, manifestChange{key, old, nil})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].key < changes[j].key })
+	return changes
+}
+
+// Returns a manifest key like "440p" as "440 Cover".
+func describeManifestKey(key string, artStyles map[string][]string) string {
+	for artStyle, artStyleExtensions := range artStyles {
+		if artStyleExtensions[0] != "" && strings.HasSuffix(key, artStyleExtensions[0]) {
+			return strings.TrimSuffix(key, artStyleExtensions[0]) + " " + artStyle
+		}
+	}
+	return key + " Banner"
+}
+
+// Describes the change of the image with the given name.
+func (change manifestChange) describe(name string) string {
+	switch {
+	case change.old == nil:
+		return "+ " + name + " from " + change.new.Source
+	case change.new == nil:
+		return "- " + name + ", was from " + change.old.Source
+	case change.old.Source != change.new.Source:
+		return "~ " + name + " changed source from " + change.old.Source + " to " + change.new.Source
+	default:
+		return "~ " + name + " image changed"
+	}
+}
+
+// PrintDiff prints, for all users, what the last run changed compared to the
+// run before it, and the images changed on disk since then. Doesn't modify
+// anything.
+func PrintDiff(options Options) error {
+	artStyles, err := getArtStyles(options)
+	if err != nil {
+		return err
+	}
+	users, err := LoadUsers(options)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		gridDir := user.GridDir
+		snapshots := listRunSnapshots(gridDir)
+		if len(snapshots) == 0 {
+			fmt.Printf("%v (%v): no runs recorded yet.\n\n", user.Name, user.SteamID32)
+			continue
+		}
+		last := snapshots[len(snapshots)-1]
+		current, err := loadRunSnapshot(gridDir, last)
+		if err != nil {
+			return err
+		}
+		previous := map[string]*ManifestEntry{}
+		if len(snapshots) > 1 {
+			previous, err = loadRunSnapshot(gridDir, snapshots[len(snapshots)-2])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%v (%v): run of %v compared to %v\n", user.Name, user.SteamID32, last, snapshots[len(snapshots)-2])
+		} else {
+			fmt.Printf("%v (%v): first recorded run, %v\n", user.Name, user.SteamID32, last)
+		}
+
+		changes := diffManifests(previous, current)
+		for _, change := range changes {
+			fmt.Println("  " + change.describe(describeManifestKey(change.key, artStyles)))
+		}
+
+		// Changes made after the run, by Steam or by hand.
+		manifest := &Manifest{filepath.Join(gridDir, "steamgrid.json"), current}
+		for _, path := range revertedImages(gridDir, manifest) {
+			name := filepath.Base(path)
+			fmt.Printf("  ! %v changed on disk after the run\n", describeManifestKey(strings.TrimSuffix(name, filepath.Ext(name)), artStyles))
+		}
+		for key, entry := range current {
+			if _, err := os.Stat(filepath.Join(gridDir, key+entry.ImageExt)); os.IsNotExist(err) {
+				fmt.Printf("  ! %v removed from disk after the run\n", describeManifestKey(key, artStyles))
+			}
+		}
+		fmt.Printf("  %v changes, %v images unchanged.\n\n", len(changes), len(current)-len(changes)+countRemoved(changes))
+	}
+	return nil
+}
+
+// Returns the number of removed entries in the changes.
+func countRemoved(changes []manifestChange) int {
+	removed := 0
+	for _, change := range changes {
+		if change.new == nil {
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,75 @@
+package steamgrid
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Checks a comma separated list of dimensions like "460x215,920x430".
+func validateDimensions(dimensions string) error {
+	if dimensions == "" {
+		return nil
+	}
+	for _, d := range strings.Split(dimensions, ",") {
+		var width, height int
+		n, err := fmt.Sscanf(strings.TrimSpace(d), "%dx%d", &width, &height)
+		if err != nil || n != 2 || width <= 0 || height <= 0 {
+			return errors.New("Invalid dimensions " + d + ", must be like 460x215")
+		}
+	}
+	return nil
+}
+
+// Returns the first of a comma separated list of dimensions, the one image
+// searches ask for.
+func firstDimensions(dimensions string) string {
+	return strings.TrimSpace(strings.Split(dimensions, ",")[0])
+}
+
+// Returns the size to ask image search engines for. Defaults to the old
+// banner format, since search engines are mostly used for banners.
+func searchDimensions(artStyleExtensions []string) (int, int) {
+	width, height := 460, 215
+	if len(artStyleExtensions) > 4 && artStyleExtensions[4] != "" {
+		fmt.Sscanf(artStyleExtensions[4], "%dx%d", &width, &height)
+	}
+	return width, height
+}
+
+// Widest hero kept with --hero-size small, the size Steam shows on 1080p
+// displays.
+const smallHeroWidth = 1920
+
+// Returns the comma separated dimensions that are at most maxWidth wide.
+func smallDimensions(dimensions string, maxWidth int) string {
+	var small []string
+	for _, d := range strings.Split(dimensions, ",") {
+		var width, height int
+		fmt.Sscanf(strings.TrimSpace(d), "%dx%d", &width, &height)
+		if width <= maxWidth {
+			small = append(small, strings.TrimSpace(d))
+		}
+	}
+	return strings.Join(small, ",")
+}
+
+// Crops the center of the image to the aspect ratio of width:height and
+// scales it to that size.
+func fitToSize(img image.Image, width int, height int) image.Image {
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(result, result.Bounds(), img, cropToAspect(img.Bounds(), width, height), draw.Src, nil)
+	return result
+}
+
+// Scales the image down to the width, keeping its aspect ratio.
+func scaleToWidth(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(result, result.Bounds(), img, bounds, draw.Src, nil)
+	return result
+}
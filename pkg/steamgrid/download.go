@@ -0,0 +1,983 @@
+package steamgrid
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.deanishe.net/fuzzy"
+)
+
+// When all else fails, Google it. Uses the regular web interface. There are
+// two image search APIs, but one is deprecated and doesn't support exact size
+// matching, and the other requires an API key limited to 100 searches a day.
+const googleSearchFormat = `https://www.google.com.br/search?tbs=isz%%3Aex%%2Ciszw%%3A%v%%2Ciszh%%3A%v&tbm=isch&num=5&q=`
+
+// Same, but for images larger than one of Google's predefined sizes and with
+// the same orientation.
+const googleLargerSearchFormat = `https://www.google.com.br/search?tbs=isz%%3Alt%%2Cislt%%3A%v%%2Ciar%%3A%v&tbm=isch&num=5&q=`
+
+// Google's predefined minimum sizes, by width.
+var googleMinimumSizes = []struct {
+	width int
+	name  string
+}{{400, "qsvga"}, {640, "vga"}, {800, "svga"}, {1024, "xga"}, {1600, "2mp"}}
+
+// Returns the Google "larger than" size and aspect ratio filters for the
+// dimensions.
+func googleLargerFilters(width int, height int) (string, string) {
+	size := googleMinimumSizes[0].name
+	for _, minimum := range googleMinimumSizes {
+		if minimum.width <= width {
+			size = minimum.name
+		}
+	}
+	aspect := "s"
+	if width >= 3*height {
+		aspect = "xw"
+	} else if width > height {
+		aspect = "w"
+	} else if height > width {
+		aspect = "t"
+	}
+	return size, aspect
+}
+
+// Possible Google result formats
+var googleSearchResultPatterns = []string{`imgurl=(.+?\.(jpeg|jpg|png))&amp;imgrefurl=`, `\"ou\":\"(.+?)\",\"`}
+
+// Returns the first steam grid image URL found by Google search of a given
+// game name.
+func getGoogleImage(client *http.Client, gameName string, artStyleExtensions []string, larger bool) (string, error) {
+	if gameName == "" {
+		return "", nil
+	}
+
+	width, height := searchDimensions(artStyleExtensions)
+	searchUrl := fmt.Sprintf(googleSearchFormat, width, height)
+	if larger {
+		size, aspect := googleLargerFilters(width, height)
+		searchUrl = fmt.Sprintf(googleLargerSearchFormat, size, aspect)
+	}
+	url := searchUrl + url.QueryEscape(gameName)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// If we don't set an user agent, Google will block us because we are a
+	// bot. If we set something like "SteamGrid Image Search" it'll work, but
+	// Google will serve a simple HTML page without direct image links.
+	// So we have to lie.
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 6.3; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/39.0.2171.71 Safari/537.36")
+	response, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	response.Body.Close()
+
+	if response.StatusCode == 429 || strings.Contains(response.Request.URL.Path, "/sorry/") {
+		return "", errors.New("Google blocked the image search, try another one with --search-engine")
+	}
+
+	for _, googleSearchResultPattern := range googleSearchResultPatterns {
+		pattern := regexp.MustCompile(googleSearchResultPattern)
+		matches := pattern.FindStringSubmatch(string(responseBytes))
+
+		if len(matches) >= 1 {
+			return matches[1], nil
+		}
+	}
+	return "", nil
+}
+
+// Bing supports exact sizes through the custom image size filter.
+const bingSearchFormat = `https://www.bing.com/images/search?qft=+filterui:imagesize-custom_%v_%v&form=IRFLTR&q=`
+
+// With --search-size larger, large images of the same orientation instead.
+const bingLargerSearchFormat = `https://www.bing.com/images/search?qft=+filterui:imagesize-large+filterui:aspect-%v&form=IRFLTR&q=`
+
+// Returns the Bing aspect filter for the dimensions.
+func bingAspect(width int, height int) string {
+	if width > height {
+		return "wide"
+	} else if height > width {
+		return "tall"
+	}
+	return "square"
+}
+
+// Media URLs are embedded HTML-escaped in the result tiles.
+var bingSearchResultPattern = regexp.MustCompile(`murl&quot;:&quot;(.+?)&quot;`)
+
+// Returns the first banner image URL found by Bing search of a given game
+// name.
+func getBingImage(client *http.Client, gameName string, artStyleExtensions []string, larger bool) (string, error) {
+	if gameName == "" {
+		return "", nil
+	}
+
+	width, height := searchDimensions(artStyleExtensions)
+	searchUrl := fmt.Sprintf(bingSearchFormat, width, height)
+	if larger {
+		searchUrl = fmt.Sprintf(bingLargerSearchFormat, bingAspect(width, height))
+	}
+	url := searchUrl + url.QueryEscape(gameName)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	// Same as with Google, without a browser user agent we get no results.
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 6.3; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/39.0.2171.71 Safari/537.36")
+	response, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	matches := bingSearchResultPattern.FindStringSubmatch(string(responseBytes))
+	if len(matches) >= 1 {
+		return matches[1], nil
+	}
+	return "", nil
+}
+
+// DuckDuckGo needs a per-query token from the regular search page before
+// the image results can be fetched as JSON.
+const duckDuckGoSearchURL = `https://duckduckgo.com/?iax=images&ia=images&q=`
+const duckDuckGoImagesURL = `https://duckduckgo.com/i.js?l=us-en&o=json&f=,,,,,&p=1&vqd=%v&q=%v`
+
+var duckDuckGoTokenPattern = regexp.MustCompile(`vqd=["']?([\d-]+)`)
+
+type duckDuckGoResponse struct {
+	Results []struct {
+		Image  string
+		Width  int
+		Height int
+	}
+}
+
+// Returns the first banner image URL found by DuckDuckGo search of a given
+// game name. DuckDuckGo has no exact size filter, so results are filtered
+// here.
+func getDuckDuckGoImage(client *http.Client, gameName string, artStyleExtensions []string, larger bool) (string, error) {
+	if gameName == "" {
+		return "", nil
+	}
+
+	response, err := tryDownload(client, duckDuckGoSearchURL+url.QueryEscape(gameName))
+	if err != nil || response == nil {
+		return "", err
+	}
+	pageBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	token := duckDuckGoTokenPattern.FindStringSubmatch(string(pageBytes))
+	if len(token) < 2 {
+		return "", errors.New("DuckDuckGo blocked the image search, try another one with --search-engine")
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf(duckDuckGoImagesURL, token[1], url.QueryEscape(gameName)), nil)
+	if err != nil {
+		return "", err
+	}
+	// The JSON endpoint refuses requests that don't come from its own page.
+	req.Header.Set("Referer", "https://duckduckgo.com/")
+	response, err = client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	var jsonResponse duckDuckGoResponse
+	err = json.Unmarshal(responseBytes, &jsonResponse)
+	if err != nil {
+		return "", nil
+	}
+
+	width, height := searchDimensions(artStyleExtensions)
+	for _, result := range jsonResponse.Results {
+		if result.Width == width && result.Height == height {
+			return result.Image, nil
+		} else if larger && result.Width >= width && result.Height >= height && result.Width > result.Height {
+			return result.Image, nil
+		}
+	}
+	return "", nil
+}
+
+// https://www.steamgriddb.com/api/v2
+type steamGridDBImage struct {
+	ID     int
+	Score  int
+	Style  string
+	URL    string
+	Thumb  string
+	Tags   []string
+	Author struct {
+		Name    string
+		Steam64 string
+		Avatar  string
+	}
+}
+
+type steamGridDBResponse struct {
+	Success bool
+	Data    []steamGridDBImage
+}
+
+type steamGridDBSearchResponse struct {
+	Success bool
+	Data    []struct {
+		ID       int
+		Name     string
+		Types    []string
+		Verified bool
+	}
+}
+
+// Enable fuzzy sorting
+// Default sort.Interface methods
+func (results steamGridDBSearchResponse) Len() int { return len(results.Data) }
+func (results steamGridDBSearchResponse) Swap(i, j int) {
+	results.Data[i], results.Data[j] = results.Data[j], results.Data[i]
+}
+func (results steamGridDBSearchResponse) Less(i, j int) bool {
+	return results.Data[i].Name < results.Data[j].Name
+}
+
+// Keywords implements Sortable.
+// Comparisons are based on the the full name of the contact.
+func (results steamGridDBSearchResponse) Keywords(i int) string { return results.Data[i].Name }
+
+// Returned when SteamGridDB rejects the api key.
+var errSteamGridDBAuth = errors.New("SteamGridDB authorization token is missing or invalid")
+
+// Search SteamGridDB for cover image
+const steamGridDBBaseURL = "https://www.steamgriddb.com/api/v2"
+
+// File types SteamGridDB can filter by.
+var steamGridDBMimes = []string{"image/png", "image/jpeg", "image/webp", "image/vnd.microsoft.icon"}
+
+// Checks a comma separated list of file types like "image/png,image/webp".
+func validateMimes(mimes string) error {
+	if mimes == "" {
+		return nil
+	}
+	for _, mime := range strings.Split(mimes, ",") {
+		known := false
+		for _, steamGridDBMime := range steamGridDBMimes {
+			known = known || strings.TrimSpace(mime) == steamGridDBMime
+		}
+		if !known {
+			return errors.New("Invalid file type " + mime + ", must be one of " + strings.Join(steamGridDBMimes, ", "))
+		}
+	}
+	return nil
+}
+
+// Returns the SteamGridDB filter for the file types, if any.
+func mimesFilter(mimes string) string {
+	if mimes == "" {
+		return ""
+	}
+	return "&mimes=" + strings.Replace(mimes, " ", "", -1)
+}
+
+func steamGridDBGetRequest(client *http.Client, url string, steamGridDBApiKey string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+steamGridDBApiKey)
+
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == 401 {
+		// Authorization token is missing or invalid
+		return nil, errors.New("401")
+	} else if response.StatusCode == 404 {
+		// Could not find game with that id
+		return nil, errors.New("404")
+	}
+
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+
+	return responseBytes, nil
+}
+
+// Returns up to count SteamGridDB images for the game, best first, following
+// the result pages as needed. The game is ambiguous if it had to be found by
+// a name search that didn't give an exact match.
+func getSteamGridDBImages(client *http.Client, game *Game, artStyleExtensions []string, steamGridDBApiKey string, count int) (images []steamGridDBImage, ambiguous bool, err error) {
+	if artStyleExtensions[5] == "" {
+		// Custom art style not available on SteamGridDB.
+		return nil, false, nil
+	}
+
+	// Try the preferred dimensions, then all of them (see --hero-size).
+	// It's possible to request both dimensions in one go but that'll give us scrambled results with no indicator which result has which size.
+	filters := []string{artStyleExtensions[3]}
+	if len(artStyleExtensions) > 6 && artStyleExtensions[6] != "" {
+		filters = append(filters, artStyleExtensions[6])
+	}
+	for _, filter := range filters {
+
+		// Try with game.ID which is probably steams appID
+		baseURL := steamGridDBBaseURL + "/" + artStyleExtensions[5]
+		url := baseURL + "/steam/" + game.ID + filter
+
+		var responseBytes []byte
+		var err error
+
+		// Skip requests with appID for custom games
+		if !game.Custom {
+			responseBytes, err = steamGridDBGetRequest(client, url, steamGridDBApiKey)
+		} else {
+			err = errors.New("404")
+		}
+
+		// Authorization token is missing or invalid
+		if err != nil && err.Error() == "401" {
+			return nil, false, errSteamGridDBAuth
+			// Could not find game with that id
+		} else if err != nil && err.Error() == "404" {
+			// Try searching for the name…
+			url = steamGridDBBaseURL + "/search/autocomplete/" + game.Name + filter
+			responseBytes, err = steamGridDBGetRequest(client, url, steamGridDBApiKey)
+			if err != nil && err.Error() == "401" {
+				return nil, false, errSteamGridDBAuth
+			} else if err != nil {
+				return nil, false, err
+			}
+
+			var jsonSearchResponse steamGridDBSearchResponse
+			err = json.Unmarshal(responseBytes, &jsonSearchResponse)
+			if err != nil {
+				return nil, false, errors.New("Best search match doesn't has a requested type or style")
+			}
+
+			SteamGridDBGameID := -1
+			if jsonSearchResponse.Success && len(jsonSearchResponse.Data) >= 1 {
+				fuzzy.Sort(jsonSearchResponse, game.Name)
+				SteamGridDBGameID = jsonSearchResponse.Data[0].ID
+				ambiguous = !strings.EqualFold(jsonSearchResponse.Data[0].Name, game.Name)
+			}
+
+			if SteamGridDBGameID == -1 {
+				return nil, false, nil
+			}
+
+			// …and get the url of the top result.
+			url = baseURL + "/game/" + strconv.Itoa(SteamGridDBGameID) + filter
+			responseBytes, err = steamGridDBGetRequest(client, url, steamGridDBApiKey)
+			if err != nil {
+				return nil, false, err
+			}
+		} else if err != nil {
+			return nil, false, err
+		}
+
+		// Follow the result pages until we have enough images.
+		for page := 1; ; page++ {
+			var jsonResponse steamGridDBResponse
+			err = json.Unmarshal(responseBytes, &jsonResponse)
+			if err != nil {
+				return nil, false, err
+			}
+			if !jsonResponse.Success || len(jsonResponse.Data) == 0 {
+				break
+			}
+
+			images = append(images, jsonResponse.Data...)
+			if len(images) >= count {
+				return images[:count], ambiguous, nil
+			}
+			if len(jsonResponse.Data) < steamGridDBPageSize {
+				// Last page.
+				break
+			}
+
+			responseBytes, err = steamGridDBGetRequest(client, url+"&page="+strconv.Itoa(page), steamGridDBApiKey)
+			if err != nil {
+				break
+			}
+		}
+
+		if len(images) > 0 {
+			return images, ambiguous, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// Number of results in a full page of the SteamGridDB API.
+const steamGridDBPageSize = 50
+
+// Number of SteamGridDB results checked through their thumbnails before
+// giving up on a game.
+const steamGridDBVerifiedCandidates = 5
+
+// SteamGridDB results of the last search for a game and art style, kept for
+// --candidates so the review thumbnails come from the same query instead of
+// a second one. Keyed by the searched game itself, as its ID is the parent's
+// while searching for mapped apps or with --parent-fallback. Removed by
+// steamGridDBCandidates right after the download.
+var steamGridDBResults = struct {
+	sync.Mutex
+	byGame map[steamGridDBResultKey]steamGridDBResult
+}{byGame: map[steamGridDBResultKey]steamGridDBResult{}}
+
+type steamGridDBResultKey struct {
+	game   *Game
+	suffix string
+}
+
+type steamGridDBResult struct {
+	images    []steamGridDBImage
+	ambiguous bool
+}
+
+// Keeps the results of a search for steamGridDBCandidates.
+func keepSteamGridDBResult(game *Game, artStyleExtensions []string, images []steamGridDBImage, ambiguous bool) {
+	steamGridDBResults.Lock()
+	steamGridDBResults.byGame[steamGridDBResultKey{game, artStyleExtensions[0]}] = steamGridDBResult{images, ambiguous}
+	steamGridDBResults.Unlock()
+}
+
+// Returns the URL of the first SteamGridDB image whose thumbnail passes the
+// orientation check, so only the chosen full-size image has to be downloaded.
+// With candidates > 0, that many results are fetched and kept for
+// saveSteamGridDBCandidates.
+func getVerifiedSteamGridDBImage(client *http.Client, game *Game, artStyle string, artStyleExtensions []string, steamGridDBApiKey string, candidates int) (string, error) {
+	count := steamGridDBVerifiedCandidates
+	if candidates > count {
+		count = candidates
+	}
+	images, ambiguous, err := getSteamGridDBImages(client, game, artStyleExtensions, steamGridDBApiKey, count)
+	if err != nil {
+		return "", err
+	}
+	if candidates > 0 {
+		kept := images
+		if len(kept) > candidates {
+			kept = kept[:candidates]
+		}
+		keepSteamGridDBResult(game, artStyleExtensions, kept, ambiguous)
+	}
+	if len(images) > steamGridDBVerifiedCandidates {
+		images = images[:steamGridDBVerifiedCandidates]
+	}
+
+	for _, candidate := range images {
+		if candidate.Thumb == "" || (artStyle != "Banner" && artStyle != "Cover") {
+			// Nothing to verify.
+			return candidate.URL, nil
+		}
+
+		response, err := tryDownload(client, candidate.Thumb)
+		if err != nil || response == nil {
+			continue
+		}
+		// Only the header is needed to know the dimensions.
+		config, _, err := image.DecodeConfig(response.Body)
+		response.Body.Close()
+		if err != nil {
+			// Unknown thumbnail format, let the full download decide.
+			return candidate.URL, nil
+		}
+		if hasValidOrientation(artStyle, image.Point{config.Width, config.Height}) {
+			return candidate.URL, nil
+		}
+	}
+	return "", nil
+}
+
+// Removes the SteamGridDB results kept for the game, and returns them if its
+// image came from SteamGridDB.
+func steamGridDBCandidates(game *Game, artStyleExtensions []string, from string) steamGridDBResult {
+	key := steamGridDBResultKey{game, artStyleExtensions[0]}
+	steamGridDBResults.Lock()
+	result := steamGridDBResults.byGame[key]
+	delete(steamGridDBResults.byGame, key)
+	steamGridDBResults.Unlock()
+	if from != "SteamGridDB" {
+		return steamGridDBResult{}
+	}
+	return result
+}
+
+// Downloads the thumbnails of the top SteamGridDB candidates for a game into
+// the review folder, so the right one can be picked by hand and put in the
+// 'games' folder. Thumbnails of this art style from earlier runs are removed
+// first. Returns the number of thumbnails saved.
+func saveSteamGridDBCandidates(client *http.Client, candidatesDir string, game *Game, artStyle string, result steamGridDBResult) (int, error) {
+	gameDir := filepath.Join(candidatesDir, game.ID)
+	oldCandidates, _ := filepath.Glob(filepath.Join(gameDir, strings.ToLower(artStyle)+" *"))
+	for _, oldCandidate := range oldCandidates {
+		err := os.Remove(oldCandidate)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	images := result.images
+	if !result.ambiguous || len(images) == 0 {
+		return 0, nil
+	}
+
+	err := os.MkdirAll(gameDir, 0777)
+	if err != nil {
+		return 0, err
+	}
+
+	saved := 0
+	for i, image := range images {
+		url := image.Thumb
+		if url == "" {
+			url = image.URL
+		}
+		response, err := tryDownload(client, url)
+		if err != nil || response == nil {
+			continue
+		}
+		imageBytes, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			continue
+		}
+
+		// Keep the SteamGridDB ID in the name so the full image can be found again.
+		name := fmt.Sprintf("%v %v %v%v", strings.ToLower(artStyle), i+1, image.ID, filepath.Ext(response.Request.URL.Path))
+		err = ioutil.WriteFile(filepath.Join(gameDir, name), imageBytes, 0666)
+		if err != nil {
+			return saved, err
+		}
+		saved++
+	}
+	return saved, nil
+}
+
+const igdbImageURL = "https://images.igdb.com/igdb/image/upload/t_720p/%v.jpg"
+const igdbGameURL = "https://api.igdb.com/v4/games"
+const igdbCoverURL = "https://api.igdb.com/v4/covers"
+const igdbGameBody = `fields name,cover; search "%v";`
+const igdbCoverBody = `fields image_id; where id = %v;`
+
+type igdbGame struct {
+	ID    int
+	Cover int
+	Name  string
+}
+
+type igdbCover struct {
+	ID       int
+	Image_ID string
+}
+
+func igdbPostRequest(client *http.Client, url string, body string, IGDBSecret string, IGDBClient string) ([]byte, error) {
+	reqq, err := http.NewRequest("POST", "https://id.twitch.tv/oauth2/token?client_id="+IGDBClient+"&client_secret="+IGDBSecret+"&grant_type=client_credentials", strings.NewReader(body))
+	tokenResponse, err := client.Do(reqq)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenBody, err := ioutil.ReadAll(tokenResponse.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	type token struct {
+		String string "json:\"access_token\""
+	}
+
+	token1 := token{}
+
+	jsonErr := json.Unmarshal(tokenBody, &token1)
+
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	req, err := http.NewRequest("POST", url, strings.NewReader(body))
+	req.Header.Add("Client-ID", IGDBClient)
+	req.Header.Add("Authorization", "Bearer "+token1.String)
+	req.Header.Add("Accept", "application/json")
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+
+	return responseBytes, nil
+}
+
+func getIGDBImage(client *http.Client, gameName string, IGDBSecret string, IGDBClient string) (string, error) {
+	responseBytes, err := igdbPostRequest(client, igdbGameURL, fmt.Sprintf(igdbGameBody, gameName), IGDBSecret, IGDBClient)
+	if err != nil {
+		return "", err
+	}
+
+	var jsonGameResponse []igdbGame
+	err = json.Unmarshal(responseBytes, &jsonGameResponse)
+	if err != nil {
+		return "", nil
+	}
+
+	if len(jsonGameResponse) < 1 || jsonGameResponse[0].Cover == 0 {
+		return "", nil
+	}
+
+	responseBytes, err = igdbPostRequest(client, igdbCoverURL, fmt.Sprintf(igdbCoverBody, jsonGameResponse[0].Cover), IGDBSecret, IGDBClient)
+	if err != nil {
+		return "", err
+	}
+
+	var jsonCoverResponse []igdbCover
+	err = json.Unmarshal(responseBytes, &jsonCoverResponse)
+	if err != nil {
+		return "", nil
+	}
+
+	if len(jsonCoverResponse) >= 1 {
+		return fmt.Sprintf(igdbImageURL, jsonCoverResponse[0].Image_ID), nil
+	}
+
+	return "", nil
+}
+
+// Tries to fetch a URL, returning the response only if it was positive.
+func tryDownload(client *http.Client, url string) (*http.Response, error) {
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == 404 {
+		// Some apps don't have an image and there's nothing we can do.
+		return nil, nil
+	} else if response.StatusCode >= 400 {
+		// Other errors should be reported, though.
+		return nil, errors.New("Failed to download image " + url + ": " + response.Status)
+	}
+
+	return response, nil
+}
+
+// Primary URL for downloading grid images.
+const akamaiURLFormat = `https://steamcdn-a.akamaihd.net/steam/apps/%v/`
+
+// The subreddit mentions this as primary, but I've found Akamai to contain
+// more images and answer faster.
+const steamCdnURLFormat = `cdn.akamai.steamstatic.com/steam/apps/%v/`
+
+// Tries to load the grid image for a game from a number of alternative
+// sources. Returns the final response received and the name of the provider
+// it came from (useful because we want to log the lower quality images).
+func getImageAlternatives(game *Game, artStyle string, artStyleExtensions []string, options Options) (response *http.Response, from string, err error) {
+	// A failing provider, e.g. one that is down, shouldn't keep the others
+	// from being tried. Its error is only returned if none found anything.
+	// Authentication errors are returned right away, so the caller can stop
+	// using the bad credentials for the following games.
+	var firstErr error
+	for _, provider := range getImageProviders(options) {
+		start := time.Now()
+		response, err = searchAndDownload(provider, game, artStyle, artStyleExtensions)
+		statistics.searched(provider.Name(), time.Since(start), response != nil)
+		if err == errSteamGridDBAuth || err == errScreenScraperAuth {
+			return nil, "", err
+		} else if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if response != nil {
+			if _, isSteam := provider.(steamProvider); isSteam && options.OnlyMissingArtwork {
+				// Abort if image is available
+				response.Body.Close()
+				return nil, "", nil
+			}
+			return response, provider.Name(), nil
+		}
+	}
+
+	return nil, "", firstErr
+}
+
+// Stops using the credentials rejected by a provider, so the following games
+// don't query it again. Returns true if err was an authentication error.
+func clearFailedAuth(options *Options, err error) bool {
+	switch err {
+	case errSteamGridDBAuth:
+		// Wrong api key
+		options.SteamGridDBApiKey = ""
+	case errScreenScraperAuth:
+		options.ScreenScraperDevID = ""
+	default:
+		return false
+	}
+	return true
+}
+
+// Returns the first image of the provider that can be downloaded, or nil.
+func searchAndDownload(provider ImageProvider, game *Game, artStyle string, artStyleExtensions []string) (*http.Response, error) {
+	urls, err := provider.Search(game, artStyle, artStyleExtensions)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Steam CDN is part of the provider, so its requests use the Steam
+	// timeout instead of the download one.
+	client := httpClient
+	steam, isSteam := provider.(steamProvider)
+	if isSteam && steam.client != nil {
+		client = steam.client
+	}
+	for _, url := range urls {
+		response, err := tryDownload(client, url)
+		if err != nil && !isSteam {
+			return nil, err
+		}
+		if err == nil && response != nil {
+			if isAVIFResponse(response) {
+				// Can't be decoded, a later URL or provider may have
+				// the same art in another format.
+				response.Body.Close()
+				continue
+			}
+			return response, nil
+		}
+	}
+	return nil, nil
+}
+
+// Peeks at the start of the body to tell if it's an AVIF, keeping the body
+// readable from the start.
+func isAVIFResponse(response *http.Response) bool {
+	reader := bufio.NewReader(response.Body)
+	start, _ := reader.Peek(12)
+	response.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, response.Body}
+	return isAVIF(start)
+}
+
+// Banners must be landscape and covers portrait, anything else is a bad match.
+func hasValidOrientation(artStyle string, imageSize image.Point) bool {
+	if artStyle == "Banner" && imageSize.X < imageSize.Y {
+		return false
+	} else if artStyle == "Cover" && imageSize.X > imageSize.Y {
+		return false
+	}
+	return true
+}
+
+// DownloadImage tries to download the game images, saving it in game.ImageBytes. Returns
+// flags indicating if the operation succeeded and if the image downloaded was
+// from a search.
+func DownloadImage(gridDir string, game *Game, artStyle string, artStyleExtensions []string, options Options) (string, error) {
+	return downloadImage(gridDir, game, artStyle, artStyleExtensions, options, true)
+}
+
+// Same as DownloadImage, usePin tells if a pinned image should be used.
+func downloadImage(gridDir string, game *Game, artStyle string, artStyleExtensions []string, options Options, usePin bool) (string, error) {
+	var response *http.Response
+	var from string
+	var err error
+	pin, pinned := getPin(game, artStyle)
+	if pinned && usePin {
+		response, err = downloadPin(httpClient, game, pin, options.SteamGridDBApiKey)
+		if err != nil {
+			return "", err
+		}
+		from = pin.source()
+	}
+	if response == nil {
+		response, from, err = getImageAlternatives(game, artStyle, artStyleExtensions, options)
+	}
+	if response == nil && err == nil && options.ParentFallback && !game.Custom {
+		response, from, err = getParentImage(game, artStyle, artStyleExtensions, options)
+	}
+	if response == nil && err == nil && options.ScreenshotFallback && !game.Custom && artStyle != "Logo" {
+		// Logos need transparency, a screenshot would cover the hero.
+		imageBytes, err := getScreenshotImage(httpClient, game, artStyleExtensions)
+		if imageBytes == nil || err != nil {
+			return "", err
+		}
+		game.ImageExt = ".jpg"
+		game.ImageSource = screenshotSource
+		statistics.downloaded(screenshotSource, len(imageBytes))
+		game.CleanImageBytes = imageBytes
+		return screenshotSource, nil
+	}
+	if response == nil || err != nil {
+		return "", err
+	}
+
+	imageBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	// Servers often send the wrong Content-Type (e.g. octet-stream), and Steam
+	// ignores files whose extension doesn't match the format.
+	game.ImageExt = sniffImageExt(imageBytes)
+	if game.ImageExt == "" {
+		contentType := response.Header.Get("Content-Type")
+		urlExt := filepath.Ext(response.Request.URL.Path)
+		if contentType != "" {
+			game.ImageExt = "." + strings.Split(contentType, "/")[1]
+		} else if urlExt != "" {
+			game.ImageExt = urlExt
+		} else {
+			// Steam is forgiving on image extensions.
+			game.ImageExt = "jpg"
+		}
+
+		if game.ImageExt == ".jpeg" {
+			// The new library ignores .jpeg
+			game.ImageExt = ".jpg"
+		} else if game.ImageExt == ".octet-stream" {
+			// Amazonaws (steamgriddb) gives us an .octet-stream
+			game.ImageExt = ".png"
+		}
+	}
+
+	release := reserveImageMemory(imageBytes)
+	defer release()
+
+	imageBytes, err = normalizeJPEG(imageBytes)
+	if err != nil {
+		return "", err
+	}
+
+	// catch false aspect ratios. Animations are only checked by their size,
+	// decoding them would keep just the first frame, or fail for WebP.
+	animated := isAnimated(imageBytes)
+	var decoded image.Image
+	var size image.Point
+	if animated {
+		config, _, err := image.DecodeConfig(bytes.NewBuffer(imageBytes))
+		if err != nil {
+			return "", err
+		}
+		size = image.Point{config.Width, config.Height}
+	} else {
+		decoded, _, err = image.Decode(bytes.NewBuffer(imageBytes))
+		if err != nil {
+			if isAVIF(imageBytes) {
+				// Only pins get here, the providers skip AVIFs. Treat it
+				// as not found instead of failing the style.
+				release()
+				if pinned && usePin {
+					return downloadImage(gridDir, game, artStyle, artStyleExtensions, options, false)
+				}
+				return "", nil
+			}
+			return "", err
+		}
+		size = decoded.Bounds().Max
+	}
+	if !hasValidOrientation(artStyle, size) {
+		if pinned && usePin {
+			// Pinned grid meant for the other of banner and cover.
+			release()
+			return downloadImage(gridDir, game, artStyle, artStyleExtensions, options, false)
+		}
+		return "", nil
+	}
+	resized := false
+	if artStyle == "Hero" && options.HeroSize == "small" && !animated && size.X > smallHeroWidth {
+		decoded = scaleToWidth(decoded, smallHeroWidth)
+		resized = true
+	}
+	// Searches for larger images find banners of any size with roughly the
+	// right shape.
+	if from == "search" && options.SearchSize == "larger" && !animated {
+		width, height := searchDimensions(artStyleExtensions)
+		if size.X != width || size.Y != height {
+			decoded = fitToSize(decoded, width, height)
+			resized = true
+		}
+	}
+	if resized {
+		buf := new(bytes.Buffer)
+		if game.ImageExt == ".jpg" {
+			err = encodeJPEG(buf, decoded)
+		} else {
+			err = encodePNG(buf, decoded)
+			game.ImageExt = ".png"
+		}
+		if err != nil {
+			return "", err
+		}
+		imageBytes = buf.Bytes()
+	}
+	downloadedSize := len(imageBytes)
+	if !animated {
+		// Animations are converted with --animated-format instead.
+		imageBytes, game.ImageExt, err = convertToTargetFormat(imageBytes, game.ImageExt, decoded, options.TargetFormats)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	statistics.downloaded(from, downloadedSize)
+	game.ImageSource = from
+
+	game.CleanImageBytes = imageBytes
+	return from, nil
+}
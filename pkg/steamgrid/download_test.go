@@ -0,0 +1,51 @@
+package steamgrid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeProvider struct {
+	urls []string
+	err  error
+}
+
+func (fakeProvider) Name() string { return "fake" }
+
+func (p fakeProvider) Search(game *Game, artStyle string, artStyleExtensions []string) ([]string, error) {
+	return p.urls, p.err
+}
+
+func TestAuthErrorClearsKeyWhenLaterProviderSucceeds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	}))
+	defer server.Close()
+
+	defer func(providers []ImageProvider) { externalProviders = providers }(externalProviders)
+	externalProviders = nil
+	RegisterImageProvider(fakeProvider{err: errSteamGridDBAuth})
+	RegisterImageProvider(fakeProvider{urls: []string{server.URL + "/cover.png"}})
+
+	// Only the registered providers are used, the real SteamGridDB isn't.
+	options := Options{SkipSteam: true, SkipStores: true, SkipGoogle: true}
+	response, _, err := getImageAlternatives(&Game{ID: "10", Name: "Counter-Strike"}, "Cover", []string{"p", ".cover", "", "", "", "grids"}, options)
+	if response != nil {
+		response.Body.Close()
+	}
+	if err != errSteamGridDBAuth {
+		t.Fatalf("expected the SteamGridDB auth error, got %v", err)
+	}
+
+	options.SteamGridDBApiKey = "bad key"
+	if !clearFailedAuth(&options, err) {
+		t.Error("auth error not recognized")
+	}
+	if options.SteamGridDBApiKey != "" {
+		t.Error("SteamGridDB key not cleared")
+	}
+	if clearFailedAuth(&options, nil) {
+		t.Error("no error reported as an auth failure")
+	}
+}
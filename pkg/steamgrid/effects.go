@@ -0,0 +1,94 @@
+package steamgrid
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Effect drawn over the artwork of the games in a category, like an overlay
+// but generated for the image size.
+type imageEffect func(img *image.RGBA)
+
+// Effects by normalized category, applied in order before the overlay. Keys
+// may end with an overlay extension like ".cover" to only apply to that art
+// style.
+var categoryEffects = map[string][]imageEffect{}
+
+// Returns the effects for the category in the art style.
+func effectsFor(tagName string, artStyleExtensions []string) []imageEffect {
+	effects := append([]imageEffect{}, categoryEffects[tagName]...)
+	return append(effects, categoryEffects[tagName+artStyleExtensions[1]]...)
+}
+
+// Adds an effect for each of the comma separated categories.
+func addCategoryEffect(categories string, effect imageEffect) {
+	for _, category := range strings.Split(categories, ",") {
+		tagName := normalizeTag(strings.TrimSpace(category))
+		categoryEffects[tagName] = append(categoryEffects[tagName], effect)
+	}
+}
+
+// Returns an effect that desaturates and darkens the image. A strength of 0
+// keeps the image and 1 makes it a dark grey.
+func dimEffect(strength float64) (imageEffect, error) {
+	if strength < 0 || strength > 1 {
+		return nil, errors.New("Dim strength must be between 0 and 1")
+	}
+	return func(img *image.RGBA) {
+		mapPixels(img, func(c color.RGBA) color.RGBA {
+			gray := 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+			dim := func(v uint8) uint8 {
+				return uint8((float64(v)*(1-strength) + gray*strength) * (1 - strength/2))
+			}
+			return color.RGBA{dim(c.R), dim(c.G), dim(c.B), c.A}
+		})
+	}, nil
+}
+
+// Banner height the border widths are given for. Other sizes get a border
+// proportional to their shorter side.
+const borderReferenceSize = 430
+
+// Returns an effect that draws a frame of the color around the image.
+func borderEffect(c color.RGBA, width int) imageEffect {
+	return func(img *image.RGBA) {
+		bounds := img.Bounds()
+		shorter := bounds.Dx()
+		if bounds.Dy() < shorter {
+			shorter = bounds.Dy()
+		}
+		scaled := (width*shorter + borderReferenceSize/2) / borderReferenceSize
+		if scaled < 1 {
+			scaled = 1
+		}
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if x-bounds.Min.X < scaled || bounds.Max.X-x <= scaled || y-bounds.Min.Y < scaled || bounds.Max.Y-y <= scaled {
+					img.SetRGBA(x, y, c)
+				}
+			}
+		}
+	}
+}
+
+// Adds the border effects from a comma separated list of
+// category=#RRGGBB:widthpx, e.g. "Favorites=#FFD700:12px".
+func addBorderEffects(borders string) error {
+	for _, border := range strings.Split(borders, ",") {
+		parts := borderPattern.FindStringSubmatch(strings.TrimSpace(border))
+		if parts == nil {
+			return errors.New("Invalid border " + border + ", must be like Favorites=#FFD700:12px")
+		}
+		rgb, _ := strconv.ParseUint(parts[2], 16, 32)
+		width, _ := strconv.Atoi(parts[3])
+		c := color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 255}
+		addCategoryEffect(parts[1], borderEffect(c, width))
+	}
+	return nil
+}
+
+var borderPattern = regexp.MustCompile(`^(.+)=#([0-9A-Fa-f]{6}):(\d+)(?:px)?$`)
@@ -0,0 +1,160 @@
+package steamgrid
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Effects available to --effect, by name. Each takes a single number.
+var namedEffects = map[string]func(amount float64) (imageEffect, error){
+	"grayscale":  grayscaleEffect,
+	"brightness": brightnessEffect,
+	"blur":       blurEffect,
+	"dim":        dimEffect,
+}
+
+var effectCallPattern = regexp.MustCompile(`^(\w+)\(\s*(-?[0-9.]+)\s*\)$`)
+
+// Adds the effects from a comma separated list of
+// category[.style]:effect(amount) effect(amount)..., e.g.
+// "Completed:grayscale(0.8), Backlog.cover:blur(3) brightness(-0.2)".
+func addEffects(effects string, artStyles map[string][]string) error {
+	for _, entry := range strings.Split(effects, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return errors.New("Invalid effect " + entry + ", must be like Completed:grayscale(0.8)")
+		}
+
+		category := parts[0]
+		styleExtension := ""
+		for _, artStyleExtensions := range artStyles {
+			if strings.HasSuffix(strings.ToLower(category), artStyleExtensions[1]) {
+				category = category[:len(category)-len(artStyleExtensions[1])]
+				styleExtension = artStyleExtensions[1]
+			}
+		}
+
+		for _, call := range strings.Fields(parts[1]) {
+			groups := effectCallPattern.FindStringSubmatch(call)
+			if groups == nil {
+				return errors.New("Invalid effect " + call + ", must be like grayscale(0.8)")
+			}
+			newEffect, ok := namedEffects[groups[1]]
+			if !ok {
+				return errors.New("Unknown effect " + groups[1] + ", must be one of grayscale, brightness, blur or dim")
+			}
+			amount, err := strconv.ParseFloat(groups[2], 64)
+			if err != nil {
+				return err
+			}
+			effect, err := newEffect(amount)
+			if err != nil {
+				return err
+			}
+			tagName := normalizeTag(category) + styleExtension
+			categoryEffects[tagName] = append(categoryEffects[tagName], effect)
+		}
+	}
+	return nil
+}
+
+// Returns an effect that removes the given fraction of the colors.
+func grayscaleEffect(amount float64) (imageEffect, error) {
+	if amount < 0 || amount > 1 {
+		return nil, errors.New("Grayscale amount must be between 0 and 1")
+	}
+	return func(img *image.RGBA) {
+		mapPixels(img, func(c color.RGBA) color.RGBA {
+			gray := 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+			mix := func(v uint8) uint8 {
+				return uint8(float64(v)*(1-amount) + gray*amount)
+			}
+			return color.RGBA{mix(c.R), mix(c.G), mix(c.B), c.A}
+		})
+	}, nil
+}
+
+// Returns an effect that brightens (positive) or darkens (negative) the
+// image, from -1 (black) to 1 (white).
+func brightnessEffect(amount float64) (imageEffect, error) {
+	if amount < -1 || amount > 1 {
+		return nil, errors.New("Brightness must be between -1 and 1")
+	}
+	return func(img *image.RGBA) {
+		mapPixels(img, func(c color.RGBA) color.RGBA {
+			// Colors are premultiplied, so they can't go over the alpha.
+			shift := func(v uint8) uint8 {
+				shifted := float64(v) + amount*float64(c.A)
+				if shifted < 0 {
+					return 0
+				} else if shifted > float64(c.A) {
+					return c.A
+				}
+				return uint8(shifted)
+			}
+			return color.RGBA{shift(c.R), shift(c.G), shift(c.B), c.A}
+		})
+	}, nil
+}
+
+// Returns an effect that box blurs the image with the given radius in pixels,
+// for a 920x430 banner and scaled to the image size like borders.
+func blurEffect(radius float64) (imageEffect, error) {
+	if radius < 0 {
+		return nil, errors.New("Blur radius can't be negative")
+	}
+	return func(img *image.RGBA) {
+		bounds := img.Bounds()
+		shorter := bounds.Dx()
+		if bounds.Dy() < shorter {
+			shorter = bounds.Dy()
+		}
+		scaled := int(radius*float64(shorter)/borderReferenceSize + 0.5)
+		if scaled == 0 {
+			return
+		}
+		// Blurring horizontally then vertically is the same as a square blur.
+		boxBlur(img, scaled, 1, 0)
+		boxBlur(img, scaled, 0, 1)
+	}, nil
+}
+
+// Averages each pixel with its neighbors up to radius away in the direction.
+func boxBlur(img *image.RGBA, radius int, dx int, dy int) {
+	bounds := img.Bounds()
+	source := image.NewRGBA(bounds)
+	copy(source.Pix, img.Pix)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var sum [4]int
+			n := 0
+			for i := -radius; i <= radius; i++ {
+				point := image.Point{x + i*dx, y + i*dy}
+				if !point.In(bounds) {
+					continue
+				}
+				c := source.RGBAAt(point.X, point.Y)
+				sum[0] += int(c.R)
+				sum[1] += int(c.G)
+				sum[2] += int(c.B)
+				sum[3] += int(c.A)
+				n++
+			}
+			img.SetRGBA(x, y, color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), uint8(sum[3] / n)})
+		}
+	}
+}
+
+// Replaces each pixel of the image with the result of the function.
+func mapPixels(img *image.RGBA, f func(c color.RGBA) color.RGBA) {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			img.SetRGBA(x, y, f(img.RGBAAt(x, y)))
+		}
+	}
+}
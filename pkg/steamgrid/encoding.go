@@ -0,0 +1,47 @@
+package steamgrid
+
+import (
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+)
+
+// Quality of the JPEGs steamgrid encodes, from --jpeg-quality.
+var jpegQuality = 95
+
+// Encoder of the PNGs steamgrid encodes, from --png-compression.
+var pngEncoder = png.Encoder{CompressionLevel: png.DefaultCompression}
+
+// PNG compression levels by name.
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"fast":    png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+// Sets the JPEG quality (1 to 100) and PNG compression (default, none, fast
+// or best) used when re-encoding images.
+func setEncoding(quality int, compression string) error {
+	if quality < 1 || quality > 100 {
+		return errors.New("JPEG quality must be between 1 and 100, not " + strconv.Itoa(quality))
+	}
+	level, ok := pngCompressionLevels[compression]
+	if !ok {
+		return errors.New("Unknown PNG compression " + compression + ", must be one of default, none, fast or best")
+	}
+	jpegQuality = quality
+	pngEncoder.CompressionLevel = level
+	return nil
+}
+
+func encodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+}
+
+func encodePNG(w io.Writer, img image.Image) error {
+	return pngEncoder.Encode(w, img)
+}
@@ -0,0 +1,138 @@
+package steamgrid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+)
+
+// Removes the EXIF and ICC profile segments of a JPEG, rotating or flipping
+// the image as its EXIF orientation says. Steam ignores the orientation, and
+// wide gamut profiles make colors render differently than in other programs.
+// The pixels are not converted to sRGB, they are already close enough for
+// artwork. Other images are returned unchanged.
+func normalizeJPEG(imageBytes []byte) ([]byte, error) {
+	if !bytes.HasPrefix(imageBytes, []byte("\xff\xd8")) {
+		return imageBytes, nil
+	}
+
+	stripped := []byte("\xff\xd8")
+	orientation := 1
+	changed := false
+	i := 2
+	for i+4 <= len(imageBytes) && imageBytes[i] == 0xff {
+		marker := imageBytes[i+1]
+		if marker == 0xda {
+			// Start of scan, the compressed data follows.
+			break
+		}
+		length := int(binary.BigEndian.Uint16(imageBytes[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(imageBytes) {
+			return imageBytes, nil
+		}
+		payload := imageBytes[i+4 : end]
+		if marker == 0xe1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			orientation = exifOrientation(payload[6:])
+			changed = true
+		} else if marker == 0xe2 && bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")) {
+			changed = true
+		} else {
+			stripped = append(stripped, imageBytes[i:end]...)
+		}
+		i = end
+	}
+	if !changed {
+		return imageBytes, nil
+	}
+	stripped = append(stripped, imageBytes[i:]...)
+	if orientation == 1 {
+		return stripped, nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	err = encodeJPEG(buf, orient(img, orientation))
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Returns the value of the orientation tag in the TIFF data of an EXIF
+// segment, or 1 (normal) if it's missing.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+		}
+	}
+	return 1
+}
+
+// Rotates and flips the image so that it's upright, given its EXIF
+// orientation (2 to 8).
+func orient(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	var result *image.RGBA
+	if orientation >= 5 {
+		// Rotated by 90 degrees, width and height swap.
+		result = image.NewRGBA(image.Rect(0, 0, h, w))
+	} else {
+		result = image.NewRGBA(image.Rect(0, 0, w, h))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			result.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return result
+}
@@ -0,0 +1,40 @@
+package steamgrid
+
+import (
+	"fmt"
+)
+
+// Error that only affects one user or image. The run continues past it and
+// all of them are listed in the report. Errors that affect every user, like
+// not finding Steam, are returned by Run instead.
+type runFailure struct {
+	// What failed, e.g. "Portal 2 (id 620, Banner)" or "user boppreh".
+	subject string
+	// Step that failed: setup, overlay, backup or write.
+	stage string
+	err   error
+}
+
+// Steps of a run in the order they happen, to group the failures.
+var failureStages = []string{"setup", "overlay", "backup", "write"}
+
+// Describes the image of a game for the failures report.
+func imageSubject(game *Game, artStyle string) string {
+	return fmt.Sprintf("%v (id %v, %v)", game.Name, game.ID, artStyle)
+}
+
+// Prints the failures grouped by the step that failed.
+func printFailures(failures []runFailure) {
+	if len(failures) == 0 {
+		return
+	}
+	fmt.Printf("%v errors happened, the rest of the run continued:\n", len(failures))
+	for _, stage := range failureStages {
+		for _, failure := range failures {
+			if failure.stage == stage {
+				fmt.Printf("- %v failed at %v: %v\n", failure.subject, failure.stage, failure.err.Error())
+			}
+		}
+	}
+	fmt.Printf("\n\n")
+}
@@ -0,0 +1,521 @@
+package steamgrid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"sort"
+	"strings"
+
+	"github.com/kettek/apng"
+)
+
+// Output formats for animated artwork. WebP isn't one, there is no encoder
+// for animated WebPs; downloaded ones are kept as they are with apng.
+var animatedFormats = []string{"apng", "gif"}
+
+func isValidAnimatedFormat(format string) bool {
+	for _, f := range animatedFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the extension for the format of the image, detected from its first
+// bytes, or "" if it's not a known format. APNGs are PNGs.
+func sniffImageExt(imageBytes []byte) string {
+	switch {
+	case bytes.HasPrefix(imageBytes, []byte("\x89PNG\r\n\x1a\n")):
+		return ".png"
+	case bytes.HasPrefix(imageBytes, []byte("\xff\xd8\xff")):
+		return ".jpg"
+	case bytes.HasPrefix(imageBytes, []byte("GIF87a")), bytes.HasPrefix(imageBytes, []byte("GIF89a")):
+		return ".gif"
+	case len(imageBytes) >= 12 && string(imageBytes[:4]) == "RIFF" && string(imageBytes[8:12]) == "WEBP":
+		return ".webp"
+	case isAVIF(imageBytes):
+		return ".avif"
+	}
+	return ""
+}
+
+// Returns true if the image is an AVIF, which can't be decoded. Downloads
+// skip them so the next source is tried.
+func isAVIF(imageBytes []byte) bool {
+	return len(imageBytes) >= 12 && string(imageBytes[4:12]) == "ftypavif"
+}
+
+// Returns true if the image has more than one frame, or is an APNG or
+// animated WebP.
+func isAnimated(imageBytes []byte) bool {
+	switch sniffImageExt(imageBytes) {
+	case ".png":
+		// APNGs have an animation control chunk before the image data.
+		idat := bytes.Index(imageBytes, []byte("IDAT"))
+		return idat > 0 && bytes.Contains(imageBytes[:idat], []byte("acTL"))
+	case ".gif":
+		gifImage, err := gif.DecodeAll(bytes.NewBuffer(imageBytes))
+		return err == nil && len(gifImage.Image) > 1
+	case ".webp":
+		// The extended format header has a flag for animations.
+		return len(imageBytes) > 20 && string(imageBytes[12:16]) == "VP8X" && imageBytes[20]&0x02 != 0
+	}
+	return false
+}
+
+// Decodes the image, or the first frame of animations. Unlike image.Decode,
+// it also reads animated WebPs and skips the default image of APNGs, which
+// isn't part of the animation.
+func decodeFirstFrame(imageBytes []byte) (image.Image, error) {
+	if isAnimated(imageBytes) {
+		switch sniffImageExt(imageBytes) {
+		case ".png":
+			apngImage, err := apng.DecodeAll(bytes.NewBuffer(imageBytes))
+			if err != nil {
+				return nil, err
+			}
+			for _, frame := range apngImage.Frames {
+				if !frame.IsDefault {
+					return frame.Image, nil
+				}
+			}
+		case ".webp":
+			frame, err := firstWebPFrame(imageBytes)
+			if err != nil {
+				return nil, err
+			}
+			imageBytes = frame
+		}
+	}
+	decoded, _, err := image.Decode(bytes.NewBuffer(imageBytes))
+	return decoded, err
+}
+
+// Returns the first frame of an animated WebP as a still WebP, which the
+// WebP decoder can read.
+func firstWebPFrame(webpBytes []byte) ([]byte, error) {
+	chunks := webpBytes[12:]
+	for len(chunks) >= 8 {
+		size := binary.LittleEndian.Uint32(chunks[4:8])
+		if uint64(len(chunks)-8) < uint64(size) {
+			break
+		}
+		payload := chunks[8 : 8+size]
+		if string(chunks[:4]) == "ANMF" && len(payload) > 16 {
+			// Frame position, size, duration and flags, then the same chunks
+			// as a still image.
+			var flags byte
+			frameData := payload[16:]
+			if bytes.HasPrefix(frameData, []byte("ALPH")) {
+				flags = 0x10
+			}
+			header := []byte{flags, 0, 0, 0, payload[6], payload[7], payload[8], payload[9], payload[10], payload[11]}
+			still := append([]byte("VP8X\x0a\x00\x00\x00"), header...)
+			still = append(still, frameData...)
+			riff := append([]byte("RIFF"), 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(riff[4:], uint32(4+len(still)))
+			riff = append(riff, "WEBP"...)
+			return append(riff, still...), nil
+		}
+		// Chunks are padded to an even size.
+		next := 8 + int(size) + int(size%2)
+		if next > len(chunks) {
+			break
+		}
+		chunks = chunks[next:]
+	}
+	return nil, errors.New("Animated WebP has no frames")
+}
+
+// Returns an error if the chunks of a WebP don't fill exactly the size in its
+// header, as when the file was truncated. Animated WebPs can't be fully
+// decoded, so this is how their frames are checked.
+func checkWebPChunks(webpBytes []byte) error {
+	if len(webpBytes) < 12 {
+		return errors.New("Truncated WebP")
+	}
+	size := binary.LittleEndian.Uint32(webpBytes[4:8])
+	if size < 4 || uint64(size)+8 != uint64(len(webpBytes)) && uint64(size)+9 != uint64(len(webpBytes)) {
+		return errors.New("WebP size doesn't match its header")
+	}
+	chunks := webpBytes[12 : 8+size]
+	for len(chunks) > 0 {
+		if len(chunks) < 8 {
+			return errors.New("Truncated WebP chunk")
+		}
+		size := binary.LittleEndian.Uint32(chunks[4:8])
+		next := 8 + uint64(size) + uint64(size%2)
+		if next > uint64(len(chunks)) {
+			return errors.New("Truncated WebP chunk")
+		}
+		chunks = chunks[next:]
+	}
+	return nil
+}
+
+// Image formats that can be written, for --target-formats.
+var targetFormats = []string{"png", "jpg", "gif", "webp"}
+
+// Checks a comma separated list of target formats, which must include png or
+// jpg so any image can be converted.
+func validateTargetFormats(formats string) error {
+	list := strings.Split(formats, ",")
+	for _, format := range list {
+		if !contains(targetFormats, strings.TrimSpace(format)) {
+			return errors.New("Unknown target format " + format + ", must be png, jpg, gif or webp")
+		}
+	}
+	if !contains(list, "png") && !contains(list, "jpg") {
+		return errors.New("Target formats must include png or jpg")
+	}
+	return nil
+}
+
+// Re-encodes the decoded image as PNG (or JPEG if PNG is not allowed) when its
+// format is not one of the comma separated target formats. Returns the image
+// bytes and extension to write.
+func convertToTargetFormat(imageBytes []byte, ext string, decoded image.Image, formats string) ([]byte, string, error) {
+	list := strings.Split(formats, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+	if contains(list, strings.TrimPrefix(ext, ".")) {
+		return imageBytes, ext, nil
+	}
+
+	buf := new(bytes.Buffer)
+	if contains(list, "png") {
+		err := encodePNG(buf, decoded)
+		return buf.Bytes(), ".png", err
+	}
+	err := encodeJPEG(buf, decoded)
+	return buf.Bytes(), ".jpg", err
+}
+
+// Output format of each art style, from --output-formats, e.g. "Hero" ->
+// "jpg". Art styles not in the map keep the format of the image.
+var outputFormats = map[string]string{}
+
+// Parses comma separated style=format pairs, where format is png, jpg or
+// source to keep the format of the image, e.g. "hero=jpg,cover=source".
+func setOutputFormats(formats string, artStyles map[string][]string) error {
+	if formats == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(formats, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 {
+			return errors.New("Invalid output format " + entry + ", must be like hero=jpg")
+		}
+		format := strings.ToLower(parts[1])
+		if format == "jpeg" {
+			format = "jpg"
+		}
+		if format != "png" && format != "jpg" && format != "source" {
+			return errors.New("Unknown output format " + parts[1] + ", must be png, jpg or source")
+		}
+		found := false
+		for artStyle := range artStyles {
+			if strings.EqualFold(artStyle, parts[0]) {
+				if artStyle == "Logo" && format == "jpg" {
+					return errors.New("Logos can't be written as jpg, they need transparency")
+				}
+				outputFormats[artStyle] = format
+				found = true
+			}
+		}
+		if !found {
+			return errors.New("Unknown art style " + parts[0] + " in output format " + entry)
+		}
+	}
+	return nil
+}
+
+// Re-encodes the final image of the game in the output format of the art
+// style, if it has one. Animations are left alone. Returns the extension the
+// image should be written with.
+func applyOutputFormat(game *Game, artStyle string, imageExt string) (string, error) {
+	format, ok := outputFormats[artStyle]
+	if !ok || format == "source" || imageExt == "."+format || isAnimated(game.OverlayImageBytes) {
+		return imageExt, nil
+	}
+	decoded, _, err := image.Decode(bytes.NewBuffer(game.OverlayImageBytes))
+	if err != nil {
+		return imageExt, err
+	}
+	buf := new(bytes.Buffer)
+	if format == "jpg" {
+		err = encodeJPEG(buf, decoded)
+	} else {
+		err = encodePNG(buf, decoded)
+	}
+	if err != nil {
+		return imageExt, err
+	}
+	game.OverlayImageBytes = buf.Bytes()
+	return "." + format, nil
+}
+
+// Converts animated artwork in game.OverlayImageBytes to the requested output
+// format, except logos, returning the extension the image should be written
+// with.
+// Only APNG sources can be transcoded (there's no WebP encoder or animated
+// WebP decoder available), so "apng" keeps images as downloaded, animated
+// WebPs included.
+func convertAnimated(game *Game, artStyle string, animatedFormat string) (string, error) {
+	if animatedFormat != "gif" || artStyle == "Logo" || game.OverlayImageBytes == nil {
+		// GIF has no partial transparency, so logos stay APNG.
+		return game.ImageExt, nil
+	}
+
+	apngImage, err := apng.DecodeAll(bytes.NewBuffer(game.OverlayImageBytes))
+	if err != nil || len(apngImage.Frames) <= 1 {
+		// Not an animation, nothing to do.
+		return game.ImageExt, nil
+	}
+
+	gifBytes, err := encodeGif(apngImage)
+	if err != nil {
+		return game.ImageExt, err
+	}
+	game.OverlayImageBytes = gifBytes
+	return ".gif", nil
+}
+
+// Converts the clean image of a logo to PNG, or APNG if it's an animated GIF,
+// as logos are drawn over the hero and must keep their transparency (and
+// JPEGs re-encoded with overlays would get a black background). Animated
+// WebPs, which can't be decoded, are kept as they are.
+func convertLogo(game *Game) error {
+	switch sniffImageExt(game.CleanImageBytes) {
+	case ".png":
+		game.ImageExt = ".png"
+		return nil
+	case ".webp":
+		if isAnimated(game.CleanImageBytes) {
+			return nil
+		}
+	case ".gif":
+		if isAnimated(game.CleanImageBytes) {
+			apngBytes, err := gifToAPNG(game.CleanImageBytes)
+			if err != nil {
+				return err
+			}
+			game.CleanImageBytes = apngBytes
+			game.ImageExt = ".png"
+			return nil
+		}
+	}
+
+	decoded, _, err := image.Decode(bytes.NewBuffer(game.CleanImageBytes))
+	if err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	err = encodePNG(buf, decoded)
+	if err != nil {
+		return err
+	}
+	game.CleanImageBytes = buf.Bytes()
+	game.ImageExt = ".png"
+	return nil
+}
+
+// Renders the frames of an animated GIF onto a canvas and encodes them as
+// full APNG frames.
+func gifToAPNG(gifBytes []byte) ([]byte, error) {
+	gifImage, err := gif.DecodeAll(bytes.NewBuffer(gifBytes))
+	if err != nil {
+		return nil, err
+	}
+	canvas := getFrame(image.Point{gifImage.Config.Width, gifImage.Config.Height})
+	// Both formats use 0 for infinite loops, GIF uses -1 to play once.
+	result := apng.APNG{}
+	if gifImage.LoopCount > 0 {
+		result.LoopCount = uint(gifImage.LoopCount)
+	} else if gifImage.LoopCount < 0 {
+		result.LoopCount = 1
+	}
+
+	for i, frame := range gifImage.Image {
+		var previous *image.RGBA
+		disposal := byte(gif.DisposalNone)
+		if i < len(gifImage.Disposal) {
+			disposal = gifImage.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = getFrame(canvas.Rect.Max)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		rendered := getFrame(canvas.Rect.Max)
+		copy(rendered.Pix, canvas.Pix)
+		delay := 0
+		if i < len(gifImage.Delay) {
+			delay = gifImage.Delay[i]
+		}
+		result.Frames = append(result.Frames, apng.Frame{
+			Image: rendered,
+			// GIF delays are in hundredths of a second.
+			DelayNumerator:   uint16(delay),
+			DelayDenominator: 100,
+			DisposeOp:        apng.DISPOSE_OP_NONE,
+			BlendOp:          apng.BLEND_OP_SOURCE,
+		})
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			putFrame(canvas)
+			canvas = previous
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	err = apng.Encode(buf, result)
+	putFrames(result.Frames)
+	putFrame(canvas)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Renders all APNG frames onto a canvas and quantizes them to GIF palettes.
+func encodeGif(apngImage apng.APNG) ([]byte, error) {
+	var frames []apng.Frame
+	for _, frame := range apngImage.Frames {
+		// The default image isn't part of the animation.
+		if !frame.IsDefault {
+			frames = append(frames, frame)
+		}
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("Animation has no frames")
+	}
+
+	bounds := frames[0].Image.Bounds()
+	canvas := getFrame(image.Point{bounds.Dx(), bounds.Dy()})
+	defer func() { putFrame(canvas) }()
+	// Both formats use 0 for infinite loops.
+	result := &gif.GIF{LoopCount: int(apngImage.LoopCount)}
+
+	for _, frame := range frames {
+		frameBounds := frame.Image.Bounds()
+		area := image.Rect(frame.XOffset, frame.YOffset, frame.XOffset+frameBounds.Dx(), frame.YOffset+frameBounds.Dy())
+
+		var previous *image.RGBA
+		if frame.DisposeOp == apng.DISPOSE_OP_PREVIOUS {
+			previous = getFrame(canvas.Rect.Max)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		op := draw.Src
+		if frame.BlendOp == apng.BLEND_OP_OVER {
+			op = draw.Over
+		}
+		draw.Draw(canvas, area, frame.Image, frameBounds.Min, op)
+
+		result.Image = append(result.Image, quantizeGifFrame(canvas))
+		// Transparent pixels must not show the previous frame.
+		result.Disposal = append(result.Disposal, gif.DisposalBackground)
+
+		// GIF delays are in hundredths of a second.
+		denominator := int(frame.DelayDenominator)
+		if denominator == 0 {
+			denominator = 100
+		}
+		result.Delay = append(result.Delay, int(frame.DelayNumerator)*100/denominator)
+
+		switch frame.DisposeOp {
+		case apng.DISPOSE_OP_BACKGROUND:
+			draw.Draw(canvas, area, image.Transparent, image.Point{}, draw.Src)
+		case apng.DISPOSE_OP_PREVIOUS:
+			putFrame(canvas)
+			canvas = previous
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	err := gif.EncodeAll(buf, result)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Converts a frame to a paletted image with the 255 most common colors of the
+// frame and a transparent entry at index 0. GIF has no partial transparency,
+// so pixels are either transparent or opaque.
+func quantizeGifFrame(frame *image.RGBA) *image.Paletted {
+	// Colors are counted with 5 bits per channel, so similar shades share
+	// an entry.
+	key := func(r, g, b uint8) int {
+		return int(r>>3)<<10 | int(g>>3)<<5 | int(b>>3)
+	}
+	opaque := func(i int) (r, g, b uint8, ok bool) {
+		a := frame.Pix[i+3]
+		if a < 128 {
+			return 0, 0, 0, false
+		}
+		// Undo the premultiplied alpha.
+		unpremultiply := func(v uint8) uint8 { return uint8(int(v) * 255 / int(a)) }
+		return unpremultiply(frame.Pix[i]), unpremultiply(frame.Pix[i+1]), unpremultiply(frame.Pix[i+2]), true
+	}
+
+	counts := map[int]int{}
+	for i := 0; i < len(frame.Pix); i += 4 {
+		if r, g, b, ok := opaque(i); ok {
+			counts[key(r, g, b)]++
+		}
+	}
+	keys := make([]int, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > 255 {
+		keys = keys[:255]
+	}
+	colors := color.Palette{color.RGBA{}}
+	for _, k := range keys {
+		// The middle of the 5 bit range.
+		channel := func(shift uint) uint8 { return uint8((k>>shift)&31)<<3 | 4 }
+		colors = append(colors, color.RGBA{channel(10), channel(5), channel(0), 255})
+	}
+
+	// Nearest palette entry of each 5 bit color, looked up once.
+	nearest := map[int]uint8{}
+	bounds := frame.Bounds()
+	paletted := image.NewPaletted(bounds, colors)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, ok := opaque(frame.PixOffset(x, y))
+			if !ok {
+				// Index 0 is transparent, and new images start at 0.
+				continue
+			}
+			k := key(r, g, b)
+			index, found := nearest[k]
+			if !found {
+				index = uint8(colors[1:].Index(color.RGBA{r, g, b, 255}) + 1)
+				nearest[k] = index
+			}
+			paletted.SetColorIndex(x, y, index)
+		}
+	}
+	return paletted
+}
@@ -0,0 +1,54 @@
+package steamgrid
+
+import (
+	"image"
+	"sync"
+
+	"github.com/kettek/apng"
+)
+
+// Frame buffers of animations by size, reused between frames and images so
+// long animations don't allocate a new buffer for every frame.
+var framePools = struct {
+	sync.Mutex
+	pools map[image.Point]*sync.Pool
+}{pools: map[image.Point]*sync.Pool{}}
+
+func framePool(size image.Point) *sync.Pool {
+	framePools.Lock()
+	defer framePools.Unlock()
+	pool, ok := framePools.pools[size]
+	if !ok {
+		pool = &sync.Pool{New: func() interface{} {
+			return image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+		}}
+		framePools.pools[size] = pool
+	}
+	return pool
+}
+
+// Returns a transparent frame of the size, from the pool if possible.
+func getFrame(size image.Point) *image.RGBA {
+	frame := framePool(size).Get().(*image.RGBA)
+	for i := range frame.Pix {
+		frame.Pix[i] = 0
+	}
+	return frame
+}
+
+// Gives a frame back to the pool. It must not be used anymore.
+func putFrame(frame *image.RGBA) {
+	if frame.Rect.Min != (image.Point{}) {
+		return
+	}
+	framePool(frame.Rect.Max).Put(frame)
+}
+
+// Gives the frames of an encoded animation back to the pool.
+func putFrames(frames []apng.Frame) {
+	for _, frame := range frames {
+		if rgba, ok := frame.Image.(*image.RGBA); ok {
+			putFrame(rgba)
+		}
+	}
+}
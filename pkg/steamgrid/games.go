@@ -1,10 +1,8 @@
-package main
+package steamgrid
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
-	"hash/crc32"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -32,6 +30,8 @@ type Game struct {
 	Custom bool
 	// LegacyID used in BigPicture
 	LegacyID uint64
+	// App type, like "music", if the image is the artwork of the parent game.
+	ParentType string
 }
 
 // Pattern of game declarations in the public profile. It's actually JSON
@@ -53,7 +53,7 @@ func addGamesFromProfile(user User, games map[string]*Game) (err error) {
 		gameID := groups[1]
 		gameName := groups[2]
 		tags := []string{""}
-		games[gameID] = &Game{gameID, gameName, tags, "", nil, nil, "", false, 0}
+		games[gameID] = &Game{gameID, gameName, tags, "", nil, nil, "", false, 0, ""}
 	}
 
 	return
@@ -91,7 +91,7 @@ func addUnknownGames(user User, games map[string]*Game) {
 				// If for some reason it wasn't included in the profile, create a new
 				// entry for it now. Unfortunately we don't have a name.
 				gameName := ""
-				games[gameID] = &Game{gameID, gameName, []string{tag}, "", nil, nil, "", false, 0}
+				games[gameID] = &Game{gameID, gameName, []string{tag}, "", nil, nil, "", false, 0, ""}
 			}
 		}
 	}
@@ -100,10 +100,10 @@ func addUnknownGames(user User, games map[string]*Game) {
 // Adds non-Steam games that have been registered locally.
 // This information is in the file config/shortcuts.vdf, in binary format.
 // It contains the non-Steam games with names, target (exe location) and
-// tags/categories. To create a grid image we must compute the Steam ID, which
-// is just crc32(target + label) + "02000000", using IEEE standard polynomials.
+// tags/categories. The IDs used for grid images are explained in
+// shortcutids.go.
 func addNonSteamGames(user User, games map[string]*Game) {
-	shortcutsVdf := filepath.Join(user.Dir, "config", "shortcuts.vdf")
+	shortcutsVdf := filepath.Join(findInsensitive(user.Dir, "config"), "shortcuts.vdf")
 	if _, err := os.Stat(shortcutsVdf); err != nil {
 		return
 	}
@@ -114,18 +114,19 @@ func addNonSteamGames(user User, games map[string]*Game) {
 
 	// The actual binary format is known, but using regexes is way easier than
 	// parsing the entire file. If I run into any problems I'll replace this.
-	gamePattern := regexp.MustCompile("(?i)\x00\x02appid\x00(.{1,4})\x01appname\x00([^\x08]+?)\x00\x01exe\x00([^\x08]+?)\x00\x01.+?\x00tags\x00(?:\x01([^\x08]+?)|)\x08\x08")
+	// Shortcuts created by older clients have no appid field.
+	gamePattern := regexp.MustCompile("(?is)\x00(?:\x02appid\x00(.{4}))?\x01appname\x00([^\x08]+?)\x00\x01exe\x00([^\x08]+?)\x00\x01.+?\x00tags\x00(?:\x01([^\x08]+?)|)\x08\x08")
 	tagsPattern := regexp.MustCompile("\\d\x00([^\x00\x01\x08]+?)\x00")
 	for _, gameGroups := range gamePattern.FindAllSubmatch(shortcutBytes, -1) {
-		gameID := fmt.Sprint(binary.LittleEndian.Uint32(gameGroups[1]))
 		gameName := gameGroups[2]
-
-		// BigPicture is still using these
 		target := gameGroups[3]
-		uniqueName := bytes.Join([][]byte{target, gameName}, []byte(""))
-		LegacyID := uint64(crc32.ChecksumIEEE(uniqueName)) | 0x80000000
+		LegacyID := uint64(shortcutLegacyID(target, gameName))
+		gameID := fmt.Sprint(LegacyID)
+		if len(gameGroups[1]) == 4 {
+			gameID = fmt.Sprint(binary.LittleEndian.Uint32(gameGroups[1]))
+		}
 
-		game := Game{gameID, string(gameName), []string{}, "", nil, nil, "", true, LegacyID}
+		game := Game{gameID, string(gameName), []string{}, "", nil, nil, "", true, LegacyID, ""}
 		games[gameID] = &game
 
 		tagsText := gameGroups[4]
@@ -143,16 +144,34 @@ func GetGames(user User, nonSteamOnly bool, appIDs string) map[string]*Game {
 
 	if appIDs != "" {
 		for _, appID := range strings.Split(appIDs, ",") {
-			games[appID] = &Game{appID, "", []string{}, "", nil, nil, "", false, 0}
+			games[appID] = &Game{appID, "", []string{}, "", nil, nil, "", false, 0, ""}
 		}
 		return games
 	}
 
 	if !nonSteamOnly {
-		addGamesFromProfile(user, games)
+		err := addGamesFromProfile(user, games)
+		if err != nil || len(games) == 0 {
+			// Private profile, look for the owned games locally.
+			err = addGamesFromLicenses(user, games)
+			if err != nil {
+				fmt.Printf("Could not list the games of %v, only categorized and installed games will be processed: %v\n", user.Name, err.Error())
+			}
+		}
 		addUnknownGames(user, games)
 	}
 	addNonSteamGames(user, games)
+	addPlaylistNames(user, games)
+	addCollections(user, games, !nonSteamOnly)
 
 	return games
 }
+
+// Prefix of the categories that turn off an art style for a game, e.g.
+// "steamgrid:skip-hero" keeps Steam's default hero.
+const skipArtStyleTagPrefix = "steamgrid:skip-"
+
+// Returns true if the game has a category turning off the art style.
+func skipsArtStyle(game *Game, artStyle string) bool {
+	return hasTag(game, skipArtStyleTagPrefix+strings.ToLower(artStyle))
+}
@@ -0,0 +1,287 @@
+package steamgrid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+)
+
+// Steam keeps the licenses of the logged in users in userdata/<id>/config/
+// licensecache, and the apps in each package in appcache/packageinfo.vdf.
+// Together they list the owned games without the profile, for private
+// profiles.
+
+// Random number generator of the Source engine (CUniformRandomStream), which
+// Steam uses to scramble the license cache.
+type uniformRandomStream struct {
+	idum int32
+	iy   int32
+	iv   [32]int32
+}
+
+const (
+	randomIA   = 16807
+	randomIM   = 2147483647
+	randomIQ   = 127773
+	randomIR   = 2836
+	randomNDIV = 1 + (randomIM-1)/32
+)
+
+func newUniformRandomStream(seed int32) *uniformRandomStream {
+	if seed > 0 {
+		seed = -seed
+	}
+	return &uniformRandomStream{idum: seed}
+}
+
+// Advances the generator, as in Numerical Recipes' ran1.
+func (r *uniformRandomStream) next() int32 {
+	step := func() {
+		k := r.idum / randomIQ
+		r.idum = randomIA*(r.idum-k*randomIQ) - randomIR*k
+		if r.idum < 0 {
+			r.idum += randomIM
+		}
+	}
+	if r.idum <= 0 || r.iy == 0 {
+		if -r.idum < 1 {
+			r.idum = 1
+		} else {
+			r.idum = -r.idum
+		}
+		for j := 32 + 7; j >= 0; j-- {
+			step()
+			if j < 32 {
+				r.iv[j] = r.idum
+			}
+		}
+		r.iy = r.iv[0]
+	}
+	step()
+	j := r.iy / randomNDIV
+	if j >= 32 || j < 0 {
+		j = (j % 32) & 0x7fffffff
+	}
+	r.iy = r.iv[j]
+	r.iv[j] = r.idum
+	return r.iy
+}
+
+// Returns the license cache unscrambled with the account ID of its user.
+func decryptLicenseCache(data []byte, accountID uint32) []byte {
+	random := newUniformRandomStream(int32(accountID))
+	decrypted := make([]byte, len(data))
+	for i, b := range data {
+		decrypted[i] = b ^ byte(random.next()%256)
+	}
+	return decrypted
+}
+
+// Reads a protobuf varint, returning the value and its length, or a length
+// of 0 if it's cut short.
+func readVarint(data []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		value |= uint64(data[i]&0x7f) << (7 * uint(i))
+		if data[i] < 0x80 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// Calls field for each field of a protobuf message, with the value of
+// varints or the bytes of length-delimited fields. Stops at the first field
+// it can't read, like the checksum at the end of the license cache.
+func walkProtobuf(data []byte, field func(number uint64, value uint64, payload []byte)) {
+	for len(data) > 0 {
+		key, n := readVarint(data)
+		if n == 0 {
+			return
+		}
+		data = data[n:]
+		switch key & 7 {
+		case 0:
+			value, n := readVarint(data)
+			if n == 0 {
+				return
+			}
+			field(key>>3, value, nil)
+			data = data[n:]
+		case 1, 5:
+			size := 8
+			if key&7 == 5 {
+				size = 4
+			}
+			if len(data) < size {
+				return
+			}
+			data = data[size:]
+		case 2:
+			length, n := readVarint(data)
+			if n == 0 || uint64(len(data)-n) < length {
+				return
+			}
+			field(key>>3, 0, data[n:n+int(length)])
+			data = data[n+int(length):]
+		default:
+			return
+		}
+	}
+}
+
+// Returns the package IDs of the licenses in a decrypted license cache, a
+// CMsgClientLicenseList message.
+func parseLicensePackages(data []byte) []uint32 {
+	var packages []uint32
+	walkProtobuf(data, func(number uint64, value uint64, payload []byte) {
+		if number != 2 || payload == nil {
+			return
+		}
+		walkProtobuf(payload, func(number uint64, value uint64, payload []byte) {
+			if number == 1 && payload == nil {
+				packages = append(packages, uint32(value))
+			}
+		})
+	})
+	return packages
+}
+
+// Skips a binary VDF object, calling appID for each value of its "appids"
+// child. Returns the rest of the data after the object.
+func readPackageKeyValues(data []byte, inAppIDs bool, appID func(uint32)) ([]byte, error) {
+	errTruncated := errors.New("Truncated packageinfo.vdf")
+	for {
+		if len(data) == 0 {
+			return nil, errTruncated
+		}
+		valueType := data[0]
+		data = data[1:]
+		if valueType == 0x08 || valueType == 0x0b {
+			return data, nil
+		}
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			return nil, errTruncated
+		}
+		name := string(data[:end])
+		data = data[end+1:]
+
+		size := 0
+		switch valueType {
+		case 0x00:
+			var err error
+			data, err = readPackageKeyValues(data, name == "appids", appID)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case 0x01:
+			end := bytes.IndexByte(data, 0)
+			if end < 0 {
+				return nil, errTruncated
+			}
+			size = end + 1
+		case 0x02, 0x03, 0x04, 0x06:
+			size = 4
+		case 0x07, 0x0a:
+			size = 8
+		default:
+			return nil, fmt.Errorf("Unknown value type %v in packageinfo.vdf", valueType)
+		}
+		if len(data) < size {
+			return nil, errTruncated
+		}
+		if inAppIDs && valueType == 0x02 {
+			appID(binary.LittleEndian.Uint32(data))
+		}
+		data = data[size:]
+	}
+}
+
+// Returns the appIDs in each package of packageinfo.vdf.
+func readPackageInfo(path string) (map[uint32][]uint32, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 {
+		return nil, errors.New("Truncated packageinfo.vdf")
+	}
+	version := binary.LittleEndian.Uint32(data)
+	if version != 0x06565527 && version != 0x06565528 {
+		return nil, fmt.Errorf("Unknown packageinfo.vdf version %x", version)
+	}
+	data = data[8:]
+
+	packages := map[uint32][]uint32{}
+	for len(data) >= 4 {
+		packageID := binary.LittleEndian.Uint32(data)
+		if packageID == 0xffffffff {
+			break
+		}
+		// Package ID, SHA-1, change number and, since version 28, token.
+		header := 4 + 20 + 4
+		if version == 0x06565528 {
+			header += 8
+		}
+		if len(data) < header {
+			return nil, errors.New("Truncated packageinfo.vdf")
+		}
+		var appIDs []uint32
+		data, err = readPackageKeyValues(data[header:], false, func(id uint32) {
+			appIDs = append(appIDs, id)
+		})
+		if err != nil {
+			return nil, err
+		}
+		packages[packageID] = appIDs
+	}
+	return packages, nil
+}
+
+// Adds the games in the licenses of the user, without names. Used when the
+// profile is private, so more than the categorized and installed games get
+// images.
+func addGamesFromLicenses(user User, games map[string]*Game) error {
+	accountID, err := strconv.ParseUint(user.SteamID32, 10, 32)
+	if err != nil {
+		return err
+	}
+	licenseBytes, err := ioutil.ReadFile(filepath.Join(findInsensitive(user.Dir, "config"), "licensecache"))
+	if err != nil {
+		return err
+	}
+	// Steam's install dir, from userdata/<id>.
+	packageInfo, err := readPackageInfo(filepath.Join(filepath.Dir(filepath.Dir(user.Dir)), "appcache", "packageinfo.vdf"))
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, packageID := range parseLicensePackages(decryptLicenseCache(licenseBytes, uint32(accountID))) {
+		appIDs, ok := packageInfo[packageID]
+		if !ok {
+			continue
+		}
+		found = true
+		// Package 0 is Steam itself.
+		if packageID == 0 {
+			continue
+		}
+		for _, id := range appIDs {
+			gameID := fmt.Sprint(id)
+			if _, ok := games[gameID]; !ok {
+				games[gameID] = &Game{gameID, "", []string{}, "", nil, nil, "", false, 0, ""}
+			}
+		}
+	}
+	if !found {
+		return errors.New("Could not read the licenses of " + user.Name)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package steamgrid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// The fixtures in testdata are the license cache of account 12345678 with
+// packages 0 (Steam), 54029 and 469, and a version 28 packageinfo.vdf with
+// the apps of those packages.
+const fixtureAccountID = 12345678
+
+func TestDecryptLicenseCache(t *testing.T) {
+	licenseBytes, err := ioutil.ReadFile(filepath.Join("testdata", "licensecache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages := parseLicensePackages(decryptLicenseCache(licenseBytes, fixtureAccountID))
+	if !reflect.DeepEqual(packages, []uint32{0, 54029, 469}) {
+		t.Errorf("unexpected packages %v", packages)
+	}
+
+	// Another account's key gives garbage, not the same packages.
+	packages = parseLicensePackages(decryptLicenseCache(licenseBytes, fixtureAccountID+1))
+	if reflect.DeepEqual(packages, []uint32{0, 54029, 469}) {
+		t.Errorf("decrypted with the wrong account ID")
+	}
+}
+
+func TestReadPackageInfo(t *testing.T) {
+	packages, err := readPackageInfo(filepath.Join("testdata", "packageinfo.vdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[uint32][]uint32{
+		0:     {7},
+		54029: {292030, 378648},
+		469:   {220, 380, 420},
+	}
+	if !reflect.DeepEqual(packages, expected) {
+		t.Errorf("unexpected packages %v", packages)
+	}
+}
+
+func TestAddGamesFromLicenses(t *testing.T) {
+	steamDir, err := ioutil.TempDir("", "steamgrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(steamDir)
+	user := User{Name: "fixture", SteamID32: "12345678", Dir: filepath.Join(steamDir, "userdata", "12345678")}
+	for from, to := range map[string]string{
+		"licensecache":    filepath.Join(user.Dir, "config", "licensecache"),
+		"packageinfo.vdf": filepath.Join(steamDir, "appcache", "packageinfo.vdf"),
+	} {
+		fixtureBytes, err := ioutil.ReadFile(filepath.Join("testdata", from))
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.MkdirAll(filepath.Dir(to), 0777)
+		err = ioutil.WriteFile(to, fixtureBytes, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	games := map[string]*Game{}
+	err = addGamesFromLicenses(user, games)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for id := range games {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	// Not app 7, which is Steam itself.
+	if !reflect.DeepEqual(ids, []string{"220", "292030", "378648", "380", "420"}) {
+		t.Errorf("unexpected games %v", ids)
+	}
+}
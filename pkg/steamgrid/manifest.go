@@ -0,0 +1,175 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestEntry records what steamgrid did to one image of a game.
+type ManifestEntry struct {
+	// Where the clean image came from (steam server, SteamGridDB, backup...).
+	Source string
+	// Extension of the image written to the grid dir.
+	ImageExt string
+	// Hash of the image written to the grid dir.
+	Hash string
+	// Hash of the clean image, without overlays. Also the hash of the backup.
+	CleanHash string
+	// File name of the backup in the originals folder.
+	Backup string `json:",omitempty"`
+	// Categories whose overlays were applied to the image.
+	OverlayTags []string
+	// App type if the image is the artwork of the parent game, see
+	// --parent-fallback.
+	ParentType string `json:",omitempty"`
+	// Keys of the copies of the image for older clients and Big Picture
+	// mode, see alternateGridIDs.
+	Copies []string `json:",omitempty"`
+	// When the image was written.
+	Time time.Time
+}
+
+// Manifest of the images steamgrid has written for a user, saved as JSON in
+// the grid directory.
+type Manifest struct {
+	path    string
+	Entries map[string]*ManifestEntry
+}
+
+// LoadManifest from the given grid dir. Returns an empty manifest if there's
+// none yet or it can't be read.
+func LoadManifest(gridDir string) *Manifest {
+	manifest := &Manifest{filepath.Join(gridDir, "steamgrid.json"), map[string]*ManifestEntry{}}
+	manifestBytes, err := ioutil.ReadFile(manifest.path)
+	if err != nil {
+		return manifest
+	}
+	json.Unmarshal(manifestBytes, &manifest.Entries)
+	if manifest.Entries == nil {
+		manifest.Entries = map[string]*ManifestEntry{}
+	}
+	return manifest
+}
+
+// Get the entry for a game's art style, or nil if there is none.
+func (manifest *Manifest) Get(gameID string, artStyleExtensions []string) *ManifestEntry {
+	return manifest.Entries[gameID+artStyleExtensions[0]]
+}
+
+// Set the entry for a game's art style.
+func (manifest *Manifest) Set(gameID string, artStyleExtensions []string, entry *ManifestEntry) {
+	manifest.Entries[gameID+artStyleExtensions[0]] = entry
+}
+
+// Returns the keys of the copies of a game's image written under its
+// alternate grid IDs.
+func copyKeys(game *Game, artStyleExtensions []string) []string {
+	var keys []string
+	for _, id := range alternateGridIDs(game) {
+		keys = append(keys, id+artStyleExtensions[0])
+	}
+	return keys
+}
+
+// Returns the number of entries in this manifest that differ from the ones in
+// the previous manifest, ignoring the timestamps.
+func (manifest *Manifest) countChanged(previous map[string]*ManifestEntry) int {
+	changed := 0
+	for key, entry := range manifest.Entries {
+		old, ok := previous[key]
+		if !ok || old.Hash != entry.Hash || old.Source != entry.Source || !sameTags(old.OverlayTags, entry.OverlayTags) {
+			changed++
+		}
+	}
+	return changed
+}
+
+// Save the manifest back to the grid dir.
+func (manifest *Manifest) Save() error {
+	manifestBytes, err := json.MarshalIndent(manifest.Entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(manifest.path, manifestBytes, 0666)
+}
+
+// Folder in the grid dir with a copy of the manifest after each run, for
+// --diff and --rollback.
+const runSnapshotsDir = "steamgrid-runs"
+
+// Number of run snapshots kept for each user.
+const maxRunSnapshots = 20
+
+// Format of the snapshot names, which must sort by time and be valid file
+// names on Windows.
+const runSnapshotFormat = "2006-01-02T15-04-05"
+
+// Saves a copy of the manifest named after the time of the run and, if
+// retainImages is set, keeps its images for --rollback. Removes the oldest
+// copies.
+func (manifest *Manifest) SaveSnapshot(runTime time.Time, retainImages bool) error {
+	dir := filepath.Join(filepath.Dir(manifest.path), runSnapshotsDir)
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return err
+	}
+	manifestBytes, err := json.MarshalIndent(manifest.Entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(filepath.Join(dir, runTime.Format(runSnapshotFormat)+".json"), manifestBytes, 0666)
+	if err != nil {
+		return err
+	}
+	gridDir := filepath.Dir(manifest.path)
+	if retainImages {
+		retainRunImages(gridDir, manifest.Entries)
+	}
+	snapshots := listRunSnapshots(gridDir)
+	for len(snapshots) > maxRunSnapshots {
+		os.Remove(filepath.Join(dir, snapshots[0]+".json"))
+		snapshots = snapshots[1:]
+	}
+	pruneRunImages(gridDir)
+	return nil
+}
+
+// Returns the names of the run snapshots in the grid dir, oldest first.
+func listRunSnapshots(gridDir string) []string {
+	paths, _ := filepath.Glob(filepath.Join(gridDir, runSnapshotsDir, "*.json"))
+	var names []string
+	for _, path := range paths {
+		names = append(names, strings.TrimSuffix(filepath.Base(path), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Loads the manifest entries saved by a run.
+func loadRunSnapshot(gridDir string, name string) (map[string]*ManifestEntry, error) {
+	snapshotBytes, err := ioutil.ReadFile(filepath.Join(gridDir, runSnapshotsDir, name+".json"))
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]*ManifestEntry{}
+	err = json.Unmarshal(snapshotBytes, &entries)
+	return entries, err
+}
+
+// Returns true if both lists have the same tags in the same order.
+func sameTags(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
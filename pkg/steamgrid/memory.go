@@ -0,0 +1,68 @@
+package steamgrid
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"sync"
+)
+
+// Limits the memory used by images being processed at the same time. Images
+// wait until enough of the limit is free, but one image is always allowed so
+// a huge image can't block forever.
+type memoryLimiter struct {
+	mutex sync.Mutex
+	freed *sync.Cond
+	limit int64
+	used  int64
+}
+
+// Memory limit shared by all images, nil if there's no limit.
+var imageMemory *memoryLimiter
+
+// Limits the memory of images in flight, like "2G" or "512M". An empty string
+// removes the limit.
+func setMaxMemory(limit string) error {
+	if limit == "" {
+		imageMemory = nil
+		return nil
+	}
+	size, err := parseByteSize(limit)
+	if err != nil || size <= 0 {
+		return errors.New("Invalid memory limit " + limit + ", expected something like 2G")
+	}
+	imageMemory = &memoryLimiter{limit: int64(size)}
+	imageMemory.freed = sync.NewCond(&imageMemory.mutex)
+	return nil
+}
+
+// Blocks until the image fits in the memory limit, returning a function that
+// gives the memory back and may be called more than once. The estimate is the
+// encoded bytes plus the decoded pixels.
+func reserveImageMemory(imageBytes []byte) func() {
+	limiter := imageMemory
+	if limiter == nil {
+		return func() {}
+	}
+	size := int64(len(imageBytes))
+	if config, _, err := image.DecodeConfig(bytes.NewReader(imageBytes)); err == nil {
+		size += int64(config.Width) * int64(config.Height) * 4
+	}
+
+	limiter.mutex.Lock()
+	for limiter.used > 0 && limiter.used+size > limiter.limit {
+		limiter.freed.Wait()
+	}
+	limiter.used += size
+	limiter.mutex.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			limiter.mutex.Lock()
+			limiter.used -= size
+			limiter.mutex.Unlock()
+			limiter.freed.Broadcast()
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package steamgrid
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Writes the progress and provider statistics in the OpenMetrics text format.
+func writeMetrics(w io.Writer, p *Progress) {
+	p.mutex.Lock()
+	fmt.Fprintf(w, "# TYPE steamgrid_games gauge\n# HELP steamgrid_games Games to process in this run.\nsteamgrid_games %v\n", p.total)
+	fmt.Fprintf(w, "# TYPE steamgrid_games_processed counter\nsteamgrid_games_processed_total %v\n", p.done)
+	fmt.Fprintf(w, "# TYPE steamgrid_queue_depth gauge\n# HELP steamgrid_queue_depth Games left to process.\nsteamgrid_queue_depth %v\n", p.total-p.done)
+	fmt.Fprintf(w, "# TYPE steamgrid_downloaded_bytes counter\nsteamgrid_downloaded_bytes_total %v\n", p.bytes)
+	fmt.Fprintf(w, "# TYPE steamgrid_failures counter\n# HELP steamgrid_failures Errors and warnings shown during the run.\nsteamgrid_failures_total %v\n", p.warnings)
+	fmt.Fprintf(w, "# TYPE steamgrid_images_found counter\n")
+	for _, artStyle := range sortedKeys(p.processed) {
+		fmt.Fprintf(w, "steamgrid_images_found_total{art_style=%q} %v\n", artStyle, p.found[artStyle])
+	}
+	fmt.Fprintf(w, "# TYPE steamgrid_images_not_found counter\n")
+	for _, artStyle := range sortedKeys(p.processed) {
+		fmt.Fprintf(w, "steamgrid_images_not_found_total{art_style=%q} %v\n", artStyle, p.processed[artStyle]-p.found[artStyle])
+	}
+	p.mutex.Unlock()
+
+	statistics.mutex.Lock()
+	var providers []string
+	for provider := range statistics.byProvider {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	fmt.Fprintf(w, "# TYPE steamgrid_provider_searches counter\n")
+	for _, provider := range providers {
+		fmt.Fprintf(w, "steamgrid_provider_searches_total{provider=%q} %v\n", provider, statistics.byProvider[provider].searches)
+	}
+	fmt.Fprintf(w, "# TYPE steamgrid_provider_found counter\n")
+	for _, provider := range providers {
+		fmt.Fprintf(w, "steamgrid_provider_found_total{provider=%q} %v\n", provider, statistics.byProvider[provider].found)
+	}
+	fmt.Fprintf(w, "# TYPE steamgrid_provider_duration_seconds counter\n# UNIT steamgrid_provider_duration_seconds seconds\n")
+	for _, provider := range providers {
+		fmt.Fprintf(w, "steamgrid_provider_duration_seconds_total{provider=%q} %v\n", provider, statistics.byProvider[provider].duration.Seconds())
+	}
+	fmt.Fprintf(w, "# TYPE steamgrid_provider_bytes counter\n")
+	for _, provider := range providers {
+		fmt.Fprintf(w, "steamgrid_provider_bytes_total{provider=%q} %v\n", provider, statistics.byProvider[provider].bytes)
+	}
+	statistics.mutex.Unlock()
+	fmt.Fprintf(w, "# EOF\n")
+}
+
+// Returns the keys of the map, sorted like art styles.
+func sortedKeys(m map[string]int) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sortArtStyles(keys)
+	return keys
+}
+
+// The /metrics endpoint, started once per process and kept between runs, so
+// programs doing several runs (like the GUI) don't fail to listen again. It
+// shows the latest run.
+var metrics struct {
+	mutex    sync.Mutex
+	addr     string
+	listener net.Listener
+	progress *Progress
+}
+
+// Serves /metrics on the given address in the background, for monitoring
+// long runs with Prometheus. Later runs on the same address reuse the
+// listener.
+func serveMetrics(addr string, p *Progress) error {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.progress = p
+	if metrics.listener != nil && metrics.addr == addr {
+		return nil
+	}
+	if metrics.listener != nil {
+		metrics.listener.Close()
+		metrics.listener = nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	metrics.addr = addr
+	metrics.listener = listener
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		metrics.mutex.Lock()
+		p := metrics.progress
+		metrics.mutex.Unlock()
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		writeMetrics(w, p)
+	})
+	go http.Serve(listener, mux)
+	return nil
+}
@@ -0,0 +1,186 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode"
+)
+
+// Official list of all Steam apps with their names.
+const steamAppListURL = "https://api.steampowered.com/ISteamApps/GetAppList/v2/"
+
+// The app list is over 10MB, so it's cached and only refreshed weekly.
+const appListMaxAge = 7 * 24 * time.Hour
+
+type steamAppListResponse struct {
+	AppList struct {
+		Apps []struct {
+			AppID int
+			Name  string
+		}
+	}
+}
+
+// AppID -> name, loaded on first use. Empty if the list is not available.
+var appList map[string]string
+
+// Returns the path of the cached app list in the user cache folder.
+func appListCachePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "steamgrid", "applist.json"), nil
+}
+
+// Loads the app list from the cache, downloading it again if it's missing or
+// too old and download is set. A stale cache is still used if the download
+// fails.
+func loadAppList(download bool) map[string]string {
+	names := map[string]string{}
+	cachePath, err := appListCachePath()
+	if err != nil {
+		return names
+	}
+
+	listBytes, err := ioutil.ReadFile(cachePath)
+	info, statErr := os.Stat(cachePath)
+	if download && (err != nil || statErr != nil || time.Since(info.ModTime()) > appListMaxAge) {
+		response, err := tryDownload(httpClient, steamAppListURL)
+		if err == nil && response != nil {
+			downloaded, err := ioutil.ReadAll(response.Body)
+			response.Body.Close()
+			if err == nil && json.Valid(downloaded) {
+				listBytes = downloaded
+				if os.MkdirAll(filepath.Dir(cachePath), 0777) == nil {
+					writeFileAtomic(cachePath, listBytes, 0666)
+				}
+			}
+		}
+	}
+
+	var jsonResponse steamAppListResponse
+	if json.Unmarshal(listBytes, &jsonResponse) != nil {
+		return names
+	}
+	for _, app := range jsonResponse.AppList.Apps {
+		if app.Name != "" {
+			names[fmt.Sprint(app.AppID)] = app.Name
+		}
+	}
+	return names
+}
+
+// Returns the name of a Steam game from the app list, or "" if it's unknown.
+// Without download only an existing cache is used, so no requests are made.
+func appListName(gameID string, download bool) string {
+	if appList == nil {
+		appList = loadAppList(download)
+	}
+	return appList[gameID]
+}
+
+// Returns the name of a Steam game, from the cached app list or else from
+// the store.
+func getGameName(gameID string) string {
+	if name := appListName(gameID, true); name != "" {
+		return name
+	}
+	name, _ := getEnglishName(httpClient, gameID)
+	return name
+}
+
+// Official store API, returns the details of an app in the given language.
+const steamAppDetailsURL = "https://store.steampowered.com/api/appdetails?filters=basic&l=english&appids="
+
+type steamAppDetailsResponse map[string]struct {
+	Success bool
+	Data    appDetails
+}
+
+// Store details of an app. Fullgame is only set for apps that belong to
+// another game, like DLCs and soundtracks.
+type appDetails struct {
+	Name     string
+	Type     string
+	Fullgame struct {
+		AppID string
+		Name  string
+	}
+}
+
+// Fetches the English name of a Steam game from the store.
+func getEnglishName(client *http.Client, appID string) (string, error) {
+	response, err := tryDownload(client, steamAppDetailsURL+appID)
+	if err != nil || response == nil {
+		return "", err
+	}
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	var jsonResponse steamAppDetailsResponse
+	err = json.Unmarshal(responseBytes, &jsonResponse)
+	if err != nil {
+		return "", err
+	}
+	details, ok := jsonResponse[appID]
+	if !ok || !details.Success {
+		return "", nil
+	}
+	return details.Data.Name, nil
+}
+
+// Returns true if the name has characters outside of the Latin alphabet,
+// like localized Japanese or Russian names that external providers and
+// search engines match poorly.
+func isLocalizedName(name string) bool {
+	for _, r := range name {
+		if unicode.IsLetter(r) && !unicode.In(r, unicode.Latin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the name to use when searching external providers for the game:
+// the name from the app map, the cleaned name for shortcuts and, if enabled,
+// the English name for localized Steam games. Lookups are cached by appID,
+// since each game is searched for once per user.
+func searchName(game *Game, options Options, cache map[string]string) string {
+	if parentID, ok := mappedParent(game); ok {
+		if name, ok := cache[game.ID]; ok {
+			return name
+		}
+		name := getGameName(parentID)
+		if name == "" {
+			name = game.Name
+		}
+		cache[game.ID] = name
+		return name
+	} else if target, ok := appMap[game.ID]; ok && !isAppID(target) {
+		return target
+	}
+	if game.Custom {
+		return cleanName(game.Name, options.CleanNames)
+	}
+	if !options.EnglishNames || !isLocalizedName(game.Name) {
+		return game.Name
+	}
+	if name, ok := cache[game.ID]; ok {
+		return name
+	}
+	name, err := getEnglishName(httpClient, game.ID)
+	if err != nil || name == "" {
+		name = game.Name
+	}
+	cache[game.ID] = name
+	return name
+}
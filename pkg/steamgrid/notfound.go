@@ -0,0 +1,101 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// How long an image that wasn't found anywhere is not searched for again,
+// see --retry-notfound.
+const notFoundTTL = 30 * 24 * time.Hour
+
+// A search that found nothing. Searching with another name or other
+// providers, e.g. after adding an api key, may find something, so those
+// are remembered too.
+type notFoundVerdict struct {
+	Time      time.Time
+	Name      string
+	Providers string
+}
+
+// Images that weren't found anywhere, by game ID and art style extension
+// like the manifest. Shared by all users and saved in the user cache folder.
+type notFoundCache struct {
+	path     string
+	verdicts map[string]notFoundVerdict
+	changed  bool
+}
+
+// Loads the verdicts, or starts an empty cache if there are none.
+func loadNotFoundCache() *notFoundCache {
+	cache := &notFoundCache{verdicts: map[string]notFoundVerdict{}}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return cache
+	}
+	cache.path = filepath.Join(cacheDir, "steamgrid", "notfound.json")
+	if cacheBytes, err := ioutil.ReadFile(cache.path); err == nil {
+		json.Unmarshal(cacheBytes, &cache.verdicts)
+	}
+	return cache
+}
+
+// Returns the names of the providers a search uses, to tell if a verdict
+// still holds.
+func providerNames(options Options) string {
+	var names []string
+	for _, provider := range getImageProviders(options) {
+		names = append(names, provider.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// Returns when the image can be searched for again, and false if it should
+// be searched for now.
+func (c *notFoundCache) until(key string, name string, providers string) (time.Time, bool) {
+	verdict, ok := c.verdicts[key]
+	if !ok || verdict.Name != name || verdict.Providers != providers {
+		return time.Time{}, false
+	}
+	expiry := verdict.Time.Add(notFoundTTL)
+	return expiry, time.Now().Before(expiry)
+}
+
+// Records that a search found nothing.
+func (c *notFoundCache) record(key string, name string, providers string) {
+	c.verdicts[key] = notFoundVerdict{time.Now(), name, providers}
+	c.changed = true
+}
+
+// Forgets the verdict of an image that was found.
+func (c *notFoundCache) forget(key string) {
+	if _, ok := c.verdicts[key]; ok {
+		delete(c.verdicts, key)
+		c.changed = true
+	}
+}
+
+// Saves the verdicts, dropping the expired ones.
+func (c *notFoundCache) save() error {
+	if !c.changed || c.path == "" {
+		return nil
+	}
+	for key, verdict := range c.verdicts {
+		if time.Since(verdict.Time) > notFoundTTL {
+			delete(c.verdicts, key)
+		}
+	}
+	cacheBytes, err := json.Marshal(c.verdicts)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(c.path), 0777)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, cacheBytes, 0666)
+}
@@ -0,0 +1,138 @@
+package steamgrid
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Curated index of overlay packs, a JSON object of pack name -> zip URL,
+// kept in this repository so packs can be added without a release.
+const overlayPacksIndexURL = "https://raw.githubusercontent.com/boppreh/steamgrid/master/overlay-packs.json"
+
+// Pack with the overlays built into steamgrid, installed without a download,
+// e.g. as a starting point for editing them.
+const defaultOverlayPack = "default"
+
+// Downloads an overlay pack by name from the curated index, or directly from
+// a zip URL, and installs its images into the overlays dir. Returns the
+// installed file names.
+func InstallOverlayPack(pack string, overlaysDir string) ([]string, error) {
+	if pack == defaultOverlayPack {
+		return installDefaultOverlays(overlaysDir)
+	}
+	url := pack
+	if !strings.HasPrefix(pack, "http://") && !strings.HasPrefix(pack, "https://") {
+		index, err := downloadBytes(overlayPacksIndexURL)
+		if err != nil {
+			return nil, err
+		}
+		var packs map[string]string
+		err = json.Unmarshal(index, &packs)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		url, ok = packs[pack]
+		if !ok {
+			names := []string{defaultOverlayPack}
+			for name := range packs {
+				names = append(names, name)
+			}
+			sort.Strings(names[1:])
+			return nil, fmt.Errorf("Unknown overlay pack %v, available packs: %v, or the URL of a zip file", pack, strings.Join(names, ", "))
+		}
+	}
+
+	zipBytes, err := downloadBytes(url)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(zipBytes), int64(len(zipBytes)))
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(overlaysDir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	var installed []string
+	for _, file := range archive.File {
+		// Only the file name is used, so the zip can't write outside the
+		// overlays dir.
+		name := filepath.Base(file.Name)
+		if file.FileInfo().IsDir() || strings.HasPrefix(name, ".") || len(filterForImages([]string{name})) == 0 {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return installed, err
+		}
+		imageBytes, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return installed, err
+		}
+		if _, _, err := image.DecodeConfig(bytes.NewBuffer(imageBytes)); err != nil {
+			continue
+		}
+
+		err = writeFileAtomic(filepath.Join(overlaysDir, name), imageBytes, 0666)
+		if err != nil {
+			return installed, err
+		}
+		installed = append(installed, name)
+	}
+	if len(installed) == 0 {
+		return nil, errors.New("The overlay pack has no images")
+	}
+	return installed, nil
+}
+
+// Copies the embedded default overlays into the overlays dir, returning the
+// installed file names.
+func installDefaultOverlays(overlaysDir string) ([]string, error) {
+	files, err := defaultOverlays.ReadDir("defaultoverlays")
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(overlaysDir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	var installed []string
+	for _, file := range files {
+		imageBytes, err := defaultOverlays.ReadFile("defaultoverlays/" + file.Name())
+		if err != nil {
+			return installed, err
+		}
+		err = writeFileAtomic(filepath.Join(overlaysDir, file.Name()), imageBytes, 0666)
+		if err != nil {
+			return installed, err
+		}
+		installed = append(installed, file.Name())
+	}
+	return installed, nil
+}
+
+// Downloads the whole response body of the URL.
+func downloadBytes(url string) ([]byte, error) {
+	response, err := tryDownload(httpClient, url)
+	if err != nil {
+		return nil, err
+	} else if response == nil {
+		return nil, errors.New("Not found: " + url)
+	}
+	defer response.Body.Close()
+	return ioutil.ReadAll(response.Body)
+}
@@ -0,0 +1,287 @@
+package steamgrid
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"hash/fnv"
+	"image"
+
+	// "image/draw"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kettek/apng"
+	"golang.org/x/image/draw"
+)
+
+// Default overlays for the "favorite", "completed" and "hidden" categories,
+// used when there's no overlays folder so new users see results right away.
+//
+//go:embed defaultoverlays/*.png
+var defaultOverlays embed.FS
+
+// LoadOverlays from the given dir, returning a map of name -> image. Uses the
+// default overlays if the dir doesn't exist.
+func LoadOverlays(dir string, artStyles map[string][]string) (overlays map[string]image.Image, err error) {
+	overlays = make(map[string]image.Image, 0)
+
+	if _, err = os.Stat(dir); err != nil {
+		return loadDefaultOverlays(artStyles)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	// Animated overlays use their first frame.
+	imageExtensions := []string{"png", "apng", "jpg", "jpeg", "gif", "webp"}
+
+	for _, file := range files {
+		isImage := false
+		for _, extension := range imageExtensions {
+			isImage = isImage || strings.HasSuffix(file.Name(), extension)
+		}
+		if !isImage {
+			continue
+		}
+
+		imageBytes, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		img, err := decodeFirstFrame(imageBytes)
+		if err != nil {
+			return overlays, errors.New("Invalid overlay " + file.Name() + ": " + err.Error())
+		}
+
+		overlays[overlayName(file.Name(), artStyles)] = img
+	}
+
+	return
+}
+
+// Loads the embedded default overlays.
+func loadDefaultOverlays(artStyles map[string][]string) (map[string]image.Image, error) {
+	overlays := make(map[string]image.Image, 0)
+	files, err := defaultOverlays.ReadDir("defaultoverlays")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		imageBytes, err := defaultOverlays.ReadFile("defaultoverlays/" + file.Name())
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(bytes.NewBuffer(imageBytes))
+		if err != nil {
+			return nil, err
+		}
+		overlays[overlayName(file.Name(), artStyles)] = img
+	}
+	return overlays, nil
+}
+
+// Returns the normalized overlay name for a file name, e.g.
+// "Favorites.banner.png" becomes "favorite.banner".
+func overlayName(fileName string, artStyles map[string][]string) string {
+	name := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	for _, artStyleExtensions := range artStyles {
+		if strings.HasSuffix(name, artStyleExtensions[1]) {
+			name = strings.TrimSuffix(name, artStyleExtensions[1])
+			name = aliasTag(name)
+			name = name + artStyleExtensions[1]
+		}
+	}
+	return name
+}
+
+// Normalize tag name by applying the tag aliases, which by default lower-case
+// it and remove trailing "s" from plurals. Also, <, > and / are replaced with
+// - because you can't have them in Windows paths.
+func normalizeTag(tag string) string {
+	tagName := aliasTag(tag)
+	tagName = strings.Replace(tagName, "<", "-", -1)
+	tagName = strings.Replace(tagName, ">", "-", -1)
+	tagName = strings.Replace(tagName, "/", "-", -1)
+	return tagName
+}
+
+// Returns the normalized names of the game tags that have an overlay for the
+// art style or an effect, in the order they are applied.
+func matchingOverlayTags(game *Game, overlays map[string]image.Image, artStyleExtensions []string) []string {
+	var tagNames []string
+	for _, tag := range game.Tags {
+		tagName := normalizeTag(tag)
+		if _, ok := overlays[tagName+artStyleExtensions[1]]; ok || len(effectsFor(tagName, artStyleExtensions)) > 0 {
+			tagNames = append(tagNames, tagName)
+		}
+	}
+	return tagNames
+}
+
+// Overlays scaled to the size of the images they are applied to, kept for the
+// run so animations don't scale the same overlay for every frame. Keyed by a
+// checksum of the overlay pixels, so overlays reloaded with other contents,
+// e.g. by the server, are scaled again.
+var scaledOverlays = struct {
+	sync.Mutex
+	checksums map[image.Image]uint64
+	images    map[scaledOverlayKey]image.Image
+}{checksums: map[image.Image]uint64{}, images: map[scaledOverlayKey]image.Image{}}
+
+type scaledOverlayKey struct {
+	checksum uint64
+	size     image.Point
+}
+
+// Returns the overlay scaled to the size.
+func scaledOverlay(overlay image.Image, size image.Point) image.Image {
+	overlaySize := overlay.Bounds().Size()
+	if overlaySize == size {
+		return overlay
+	}
+
+	scaledOverlays.Lock()
+	defer scaledOverlays.Unlock()
+	checksum, ok := scaledOverlays.checksums[overlay]
+	if !ok {
+		pixels := image.NewNRGBA(overlay.Bounds())
+		draw.Draw(pixels, pixels.Bounds(), overlay, overlay.Bounds().Min, draw.Src)
+		hash := fnv.New64a()
+		hash.Write(pixels.Pix)
+		checksum = hash.Sum64()
+		scaledOverlays.checksums[overlay] = checksum
+	}
+	key := scaledOverlayKey{checksum, size}
+	if scaled, ok := scaledOverlays.images[key]; ok {
+		return scaled
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	// https://godoc.org/golang.org/x/image/draw#Kernel.Scale
+	draw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), overlay, overlay.Bounds(), draw.Over, nil)
+	scaledOverlays.images[key] = scaled
+	return scaled
+}
+
+// ApplyOverlay to the game image, depending on the category. The
+// resulting image is saved over the original.
+func ApplyOverlay(game *Game, overlays map[string]image.Image, artStyleExtensions []string) error {
+	release := reserveImageMemory(game.CleanImageBytes)
+	defer release()
+	return applyOverlay(game, overlays, artStyleExtensions)
+}
+
+// Same as ApplyOverlay, for callers that already hold the memory reservation
+// of the image.
+func applyOverlay(game *Game, overlays map[string]image.Image, artStyleExtensions []string) error {
+	if game.CleanImageBytes == nil || len(game.Tags) == 0 {
+		return nil
+	}
+	tagNames := matchingOverlayTags(game, overlays, artStyleExtensions)
+	canEncode := game.ImageExt == ".jpg" || game.ImageExt == ".jpeg" || game.ImageExt == ".png"
+	if len(tagNames) == 0 || !canEncode {
+		// Nothing to apply (or no way to save the result), so the image is
+		// written exactly as downloaded instead of being decoded and encoded
+		// again.
+		return nil
+	}
+
+	isApng := false
+	var gameImage image.Image
+	apngImage, err := apng.DecodeAll(bytes.NewBuffer(game.CleanImageBytes))
+	if err == nil {
+		if len(apngImage.Frames) > 1 {
+			isApng = true
+		} else {
+			gameImage = apngImage.Frames[0].Image
+		}
+	} else {
+		gameImage, _, err = image.Decode(bytes.NewBuffer(game.CleanImageBytes))
+		if err != nil {
+			return err
+		}
+	}
+
+	applied := false
+	for _, tagName := range tagNames {
+		effects := effectsFor(tagName, artStyleExtensions)
+		overlayImage, hasOverlay := overlays[tagName+artStyleExtensions[1]]
+
+		if isApng {
+			originalSize := apngImage.Frames[0].Image.Bounds().Max
+
+			for i, frame := range apngImage.Frames {
+				// Frames already rendered for a previous tag are drawn on
+				// in place.
+				result, inPlace := frame.Image.(*image.RGBA)
+				if !inPlace || frame.XOffset != 0 || frame.YOffset != 0 || result.Rect != image.Rect(0, 0, originalSize.X, originalSize.Y) {
+					result = getFrame(originalSize)
+					// No idea why these offsets are negative:
+					draw.Draw(result, result.Bounds(), frame.Image, image.Point{0 - frame.XOffset, 0 - frame.YOffset}, draw.Over)
+				}
+				for _, effect := range effects {
+					effect(result)
+				}
+				if hasOverlay {
+					// Scale overlay to imageSize so the images won't get that huge…
+					overlayScaled := scaledOverlay(overlayImage, originalSize)
+					draw.Draw(result, result.Bounds(), overlayScaled, overlayScaled.Bounds().Min, draw.Over)
+				}
+				apngImage.Frames[i].Image = result
+				apngImage.Frames[i].XOffset = 0
+				apngImage.Frames[i].YOffset = 0
+				apngImage.Frames[i].BlendOp = apng.BLEND_OP_OVER
+			}
+			applied = true
+		} else {
+			originalSize := gameImage.Bounds().Max
+
+			// We expect overlays in the correct format so we have to scale the image if it doesn't fit
+			size := originalSize
+			if hasOverlay {
+				size = overlayImage.Bounds().Max
+			}
+			result := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+			if originalSize.X != size.X && originalSize.Y != size.Y {
+				// scale to fit overlay
+				// https://godoc.org/golang.org/x/image/draw#Kernel.Scale
+				draw.ApproxBiLinear.Scale(result, result.Bounds(), gameImage, gameImage.Bounds(), draw.Over, nil)
+			} else {
+				draw.Draw(result, result.Bounds(), gameImage, image.ZP, draw.Src)
+			}
+			for _, effect := range effects {
+				effect(result)
+			}
+			if hasOverlay {
+				draw.Draw(result, result.Bounds(), overlayImage, image.Point{0, 0}, draw.Over)
+			}
+			gameImage = result
+			applied = true
+		}
+	}
+
+	if !applied {
+		return nil
+	}
+
+	buf := new(bytes.Buffer)
+	if game.ImageExt == ".jpg" || game.ImageExt == ".jpeg" {
+		err = encodeJPEG(buf, gameImage)
+	} else if game.ImageExt == ".png" && isApng {
+		err = apng.Encode(buf, apngImage)
+		putFrames(apngImage.Frames)
+	} else if game.ImageExt == ".png" {
+		err = encodePNG(buf, gameImage)
+	}
+	if err != nil {
+		return err
+	}
+	game.OverlayImageBytes = buf.Bytes()
+	return nil
+}
@@ -0,0 +1,75 @@
+package steamgrid
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Number of SteamGridDB heroes and logos compared when looking for a pair by
+// the same author.
+const pairCandidates = 20
+
+// Hero and logo art styles when --pair-hero-logo is set, nil otherwise.
+var pairedArtStyles map[string][]string
+
+// Hero and logo picked for each game ID. Both art styles download in
+// parallel, so the first one to ask picks the pair for both.
+var heroLogoPairs = struct {
+	sync.Mutex
+	pairs map[string]*heroLogoPair
+}{pairs: map[string]*heroLogoPair{}}
+
+type heroLogoPair struct {
+	once sync.Once
+	urls map[string]string
+	err  error
+}
+
+// Turns on picking heroes and logos by the same author, if both art styles
+// are enabled.
+func setHeroLogoPairing(enabled bool, artStyles map[string][]string) {
+	pairedArtStyles = nil
+	heroLogoPairs.pairs = map[string]*heroLogoPair{}
+	hero, okHero := artStyles["Hero"]
+	logo, okLogo := artStyles["Logo"]
+	if enabled && okHero && okLogo {
+		pairedArtStyles = map[string][]string{"Hero": hero, "Logo": logo}
+	}
+}
+
+// Returns the URL of the SteamGridDB hero or logo of the best ranked pair
+// made by the same author, or "" if no author made both.
+func getPairedSteamGridDBImage(client *http.Client, game *Game, artStyle string, steamGridDBApiKey string) (string, error) {
+	heroLogoPairs.Lock()
+	pair, ok := heroLogoPairs.pairs[game.ID]
+	if !ok {
+		pair = &heroLogoPair{}
+		heroLogoPairs.pairs[game.ID] = pair
+	}
+	heroLogoPairs.Unlock()
+
+	pair.once.Do(func() {
+		heroes, _, err := getSteamGridDBImages(client, game, pairedArtStyles["Hero"], steamGridDBApiKey, pairCandidates)
+		if err != nil {
+			pair.err = err
+			return
+		}
+		logos, _, err := getSteamGridDBImages(client, game, pairedArtStyles["Logo"], steamGridDBApiKey, pairCandidates)
+		if err != nil {
+			pair.err = err
+			return
+		}
+		// Lowest sum of ranks, so a pair of good images beats the best hero
+		// with a poor logo.
+		best := -1
+		for i, hero := range heroes {
+			for j, logo := range logos {
+				if hero.Author.Steam64 != "" && hero.Author.Steam64 == logo.Author.Steam64 && (best < 0 || i+j < best) {
+					best = i + j
+					pair.urls = map[string]string{"Hero": hero.URL, "Logo": logo.URL}
+				}
+			}
+		}
+	})
+	return pair.urls[artStyle], pair.err
+}
@@ -0,0 +1,76 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Store details by appID, shared by the art styles downloading in parallel.
+// Nil for apps without a store page.
+var appDetailsCache = struct {
+	sync.Mutex
+	details map[string]*appDetails
+}{details: map[string]*appDetails{}}
+
+// Returns the store details of a Steam app, or nil if it has no store page.
+// Each app is only fetched once per run.
+func getAppDetails(client *http.Client, appID string) (*appDetails, error) {
+	appDetailsCache.Lock()
+	defer appDetailsCache.Unlock()
+	if details, ok := appDetailsCache.details[appID]; ok {
+		return details, nil
+	}
+
+	response, err := tryDownload(client, steamAppDetailsURL+appID)
+	if err != nil || response == nil {
+		return nil, err
+	}
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var jsonResponse steamAppDetailsResponse
+	err = json.Unmarshal(responseBytes, &jsonResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	var details *appDetails
+	if app, ok := jsonResponse[appID]; ok && app.Success {
+		details = &app.Data
+	}
+	appDetailsCache.details[appID] = details
+	return details, nil
+}
+
+// Searches the artwork of the parent game of a DLC, soundtrack or tool. If
+// found, the game gets the app type as a tag, for overlays.
+func getParentImage(game *Game, artStyle string, artStyleExtensions []string, options Options) (*http.Response, string, error) {
+	details, err := getAppDetails(httpClient, game.ID)
+	if err != nil || details == nil || details.Fullgame.AppID == "" || details.Fullgame.AppID == game.ID {
+		return nil, "", err
+	}
+
+	id, name := game.ID, game.Name
+	game.ID, game.Name = details.Fullgame.AppID, details.Fullgame.Name
+	response, from, err := getImageAlternatives(game, artStyle, artStyleExtensions, options)
+	game.ID, game.Name = id, name
+	if response != nil {
+		setParentType(game, details.Type)
+	}
+	return response, from, err
+}
+
+// Marks the image of the game as the artwork of its parent game, tagging the
+// game with the app type so an overlay like "tool.banner.png" applies.
+func setParentType(game *Game, appType string) {
+	if appType == "" {
+		return
+	}
+	game.ParentType = appType
+	// The tags are shared with the other art styles.
+	game.Tags = append(append([]string{}, game.Tags...), appType)
+}
@@ -0,0 +1,126 @@
+package steamgrid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Returns the path in the extended-length form on Windows, e.g.
+// `\\?\C:\Program Files (x86)\Steam\...`, so writes to deep Steam
+// installations don't hit the 260 characters limit. Go only does this by
+// itself for absolute paths, and the grid and overlay paths may be relative.
+// Don't use the result with filepath.Glob, which reads "?" as a wildcard.
+func longPath(path string) string {
+	if runtime.GOOS != "windows" || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		// Network share, \\server\share becomes \\?\UNC\server\share.
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
+
+// Returns the entry of dir with the given name ignoring case, e.g.
+// "Config" for "config", or dir/name if there is none.
+func findInsensitive(dir string, name string) string {
+	files, err := ioutil.ReadDir(dir)
+	if err == nil {
+		for _, file := range files {
+			if file.Name() == name {
+				return filepath.Join(dir, name)
+			}
+		}
+		for _, file := range files {
+			if strings.EqualFold(file.Name(), name) {
+				return filepath.Join(dir, file.Name())
+			}
+		}
+	}
+	return filepath.Join(dir, name)
+}
+
+// Returns the grid dir of a user. Handles folders with a different case,
+// like "Config/Grid", and resolves symlinks so the images end up where the
+// link points to. The folder is only created by Run, when writing.
+func resolveGridDir(userDir string) (string, error) {
+	gridDir := findInsensitive(findInsensitive(userDir, "config"), "grid")
+	resolved, err := filepath.EvalSymlinks(gridDir)
+	if os.IsNotExist(err) {
+		return gridDir, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+// Returns the folder with the given name (overlays, overrides, ...). Uses
+// the one next to the executable if it exists, like previous versions did,
+// then the one in the user data dir (XDG_DATA_HOME, %APPDATA% or
+// ~/Library/Application Support). New folders go to the user data dir when
+// the executable is somewhere we shouldn't write to, like an AppImage mount
+// or /usr/bin.
+func DataDir(name string) string {
+	exeDir := ExecutableDir()
+	local := filepath.Join(exeDir, name)
+	if _, err := os.Stat(local); err == nil {
+		return local
+	}
+	userDir, err := userDataDir()
+	if err != nil {
+		return local
+	}
+	shared := filepath.Join(userDir, "steamgrid", name)
+	if _, err := os.Stat(shared); err == nil || isInstalled(exeDir) {
+		return shared
+	}
+	return local
+}
+
+// Returns the folder of the running executable, resolving symlinks. Falls
+// back to os.Args[0], which is relative when started from the PATH.
+func ExecutableDir() string {
+	exe, err := os.Executable()
+	if err == nil {
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		return filepath.Dir(exe)
+	}
+	return filepath.Dir(os.Args[0])
+}
+
+// Returns the per-user data folder for the platform.
+func userDataDir() (string, error) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return os.UserConfigDir()
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share"), nil
+}
+
+// Returns true if steamgrid runs from a package (AppImage, Flatpak, Snap) or
+// a system folder, instead of an unpacked zip.
+func isInstalled(exeDir string) bool {
+	if os.Getenv("APPIMAGE") != "" || os.Getenv("FLATPAK_ID") != "" || os.Getenv("SNAP") != "" {
+		return true
+	}
+	if runtime.GOOS != "windows" {
+		return strings.HasPrefix(exeDir, "/usr/") || strings.HasPrefix(exeDir, "/opt/") || strings.HasPrefix(exeDir, "/app/")
+	}
+	return false
+}
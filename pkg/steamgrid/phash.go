@@ -0,0 +1,106 @@
+package steamgrid
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math/bits"
+	"sort"
+
+	"golang.org/x/image/draw"
+)
+
+// Maximum number of different bits between the difference hashes of two
+// images that are considered the same artwork.
+const maxSimilarDistance = 5
+
+// Returns the difference hash of the image: 64 bits telling if each pixel of
+// a 9x8 grayscale thumbnail is brighter than the next one. It stays about the
+// same when the image is resized or re-encoded.
+func dHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.BiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y < small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Returns the difference hash of the image bytes, or false if they can't be
+// decoded. Animations are hashed by their first frame.
+func imageDHash(imageBytes []byte) (uint64, bool) {
+	img, _, err := image.Decode(bytes.NewBuffer(imageBytes))
+	if err != nil {
+		return 0, false
+	}
+	return dHash(img), true
+}
+
+// Returns true if both images are the same artwork: identical files, or
+// perceptually the same image, e.g. resized or saved in another format.
+func isSameImage(a []byte, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	if isAnimated(a) != isAnimated(b) {
+		return false
+	}
+	hashA, okA := imageDHash(a)
+	hashB, okB := imageDHash(b)
+	return okA && okB && bits.OnesCount64(hashA^hashB) <= maxSimilarDistance
+}
+
+// Difference hashes of the images downloaded in a run, by art style, to find
+// the same artwork assigned to different games.
+type artworkIndex map[string][]indexedArtwork
+
+type indexedArtwork struct {
+	hash uint64
+	game *Game
+}
+
+// Adds an image of the game to the index. Games downloaded for several users
+// are only added once.
+func (index artworkIndex) add(artStyle string, game *Game, imageBytes []byte) {
+	for _, artwork := range index[artStyle] {
+		if artwork.game.ID == game.ID {
+			return
+		}
+	}
+	hash, ok := imageDHash(imageBytes)
+	// Flat images, like blank logos, all look the same.
+	if ok && hash != 0 {
+		index[artStyle] = append(index[artStyle], indexedArtwork{hash, game})
+	}
+}
+
+// Prints the games that got the same artwork as another game, which usually
+// means one of them was matched to the wrong game.
+func (index artworkIndex) printDuplicates() {
+	var lines []string
+	for artStyle, artworks := range index {
+		for i := range artworks {
+			for j := i + 1; j < len(artworks); j++ {
+				if bits.OnesCount64(artworks[i].hash^artworks[j].hash) <= maxSimilarDistance {
+					a, b := artworks[i].game, artworks[j].game
+					lines = append(lines, fmt.Sprintf("* %v (id %v) and %v (id %v), %v", a.Name, a.ID, b.Name, b.ID, artStyle))
+				}
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	sort.Strings(lines)
+	fmt.Printf("%v pairs of games got the same artwork, probably a wrong match for one of them:\n", len(lines))
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	fmt.Printf("\n\n")
+}
@@ -0,0 +1,125 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Image chosen by the user for a game, downloaded as is instead of searching.
+type imagePin struct {
+	// As given, e.g. "grid:123456", to tell if the pin changed.
+	spec string
+	// Direct image URL, or the SteamGridDB asset ID and endpoint.
+	url      string
+	assetID  int
+	endpoint string
+}
+
+// Pins by game ID and art style, from --pin.
+var pins = map[string]imagePin{}
+
+// Returns the image source recorded for a pinned image.
+func (pin imagePin) source() string {
+	return "pinned " + pin.spec
+}
+
+// Returns the pin for the game's art style, if any.
+func getPin(game *Game, artStyle string) (imagePin, bool) {
+	pin, ok := pins[game.ID+"/"+artStyle]
+	return pin, ok
+}
+
+// SteamGridDB asset types accepted by --pin and the endpoints they are in.
+var steamGridDBAssetTypes = map[string]string{
+	"grid": "grids",
+	"hero": "heroes",
+	"logo": "logos",
+	"icon": "icons",
+}
+
+// Parses a comma separated list of pins like "252950=grid:123456". The value
+// is a SteamGridDB asset type and ID, an art style and image URL like
+// "cover:https://...", or just an image URL. Grids and plain URLs are used for
+// the banner or cover depending on their orientation.
+func addPins(pinList string, artStyles map[string][]string) error {
+	for _, entry := range strings.Split(pinList, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return errors.New("Invalid pin " + entry + ", must be like 252950=grid:123456")
+		}
+		gameID, spec := parts[0], parts[1]
+		pin := imagePin{spec: spec}
+
+		kind, value := "grid", spec
+		if !strings.HasPrefix(spec, "http://") && !strings.HasPrefix(spec, "https://") {
+			kindValue := strings.SplitN(spec, ":", 2)
+			if len(kindValue) != 2 {
+				return errors.New("Invalid pin " + entry + ", must be like 252950=grid:123456")
+			}
+			kind, value = strings.ToLower(kindValue[0]), kindValue[1]
+		}
+
+		var pinnedStyles []string
+		for artStyle, artStyleExtensions := range artStyles {
+			if strings.EqualFold(artStyle, kind) || (artStyleExtensions[5] != "" && steamGridDBAssetTypes[kind] == artStyleExtensions[5]) {
+				pinnedStyles = append(pinnedStyles, artStyle)
+				pin.endpoint = artStyleExtensions[5]
+			}
+		}
+		if len(pinnedStyles) == 0 {
+			return errors.New("Unknown image type " + kind + " in pin " + entry)
+		}
+
+		if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+			pin.url = value
+		} else {
+			assetID, err := strconv.Atoi(value)
+			if err != nil || pin.endpoint == "" {
+				return errors.New("Invalid SteamGridDB asset " + value + " in pin " + entry)
+			}
+			pin.assetID = assetID
+		}
+
+		for _, artStyle := range pinnedStyles {
+			pins[gameID+"/"+artStyle] = pin
+		}
+	}
+	return nil
+}
+
+// Downloads the pinned image. SteamGridDB assets are looked up among all the
+// game's images, ignoring the style filters.
+func downloadPin(client *http.Client, game *Game, pin imagePin, steamGridDBApiKey string) (*http.Response, error) {
+	if pin.url != "" {
+		return tryDownload(client, pin.url)
+	}
+	if steamGridDBApiKey == "" {
+		return nil, errors.New("Pinning SteamGridDB assets needs an api key, use --steamgriddb")
+	}
+
+	for page := 0; ; page++ {
+		url := steamGridDBBaseURL + "/" + pin.endpoint + "/steam/" + game.ID + "?nsfw=any&humor=any&page=" + strconv.Itoa(page)
+		responseBytes, err := steamGridDBGetRequest(client, url, steamGridDBApiKey)
+		if err != nil && err.Error() == "401" {
+			return nil, errSteamGridDBAuth
+		} else if err != nil {
+			return nil, err
+		}
+		var jsonResponse steamGridDBResponse
+		err = json.Unmarshal(responseBytes, &jsonResponse)
+		if err != nil {
+			return nil, err
+		}
+		if len(jsonResponse.Data) == 0 {
+			return nil, errors.New("SteamGridDB asset " + strconv.Itoa(pin.assetID) + " not found for game " + game.ID)
+		}
+		for _, image := range jsonResponse.Data {
+			if image.ID == pin.assetID {
+				return tryDownload(client, image.URL)
+			}
+		}
+	}
+}
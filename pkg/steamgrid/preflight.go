@@ -0,0 +1,68 @@
+package steamgrid
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Rough duration of a network request, for the time estimate.
+const estimatedRequestTime = 500 * time.Millisecond
+
+// What a run is going to do, shown before starting.
+type preflightSummary struct {
+	users     int
+	games     int
+	artStyles int
+	present   int
+	missing   int
+	// Upper bound, every provider is tried for every missing image.
+	requests int
+}
+
+// Counts the images already in the grid dirs and the ones to be searched.
+func preflight(users []User, userGames map[string]map[string]*Game, artStyles map[string][]string, options Options) preflightSummary {
+	summary := preflightSummary{users: len(users), artStyles: len(artStyles)}
+	providers := len(getImageProviders(options))
+	for _, user := range users {
+		games := userGames[user.Dir]
+		summary.games += len(games)
+		if !options.NonSteamOnly && options.AppIDs == "" {
+			// The public profile.
+			summary.requests++
+		}
+		for _, game := range games {
+			for artStyle, artStyleExtensions := range artStyles {
+				if skipsArtStyle(game, artStyle) {
+					continue
+				}
+				if hasGridImage(user.GridDir, game.ID, artStyleExtensions) {
+					summary.present++
+				} else {
+					summary.missing++
+					if !options.OverlayOnly {
+						summary.requests += providers
+					}
+				}
+			}
+		}
+	}
+	return summary
+}
+
+func (summary preflightSummary) print() {
+	fmt.Printf("%v games for %v users, %v art styles each.\n", summary.games, summary.users, summary.artStyles)
+	fmt.Printf("%v images already present, %v missing.\n", summary.present, summary.missing)
+	estimate := time.Duration(summary.requests) * estimatedRequestTime
+	fmt.Printf("Up to %v network requests, taking at most about %v.\n\n", summary.requests, estimate.Round(time.Second))
+}
+
+// Asks the user to confirm before continuing. Returns false unless the answer
+// starts with "y".
+func confirm(question string) bool {
+	fmt.Printf("%v [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y")
+}
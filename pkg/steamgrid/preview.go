@@ -0,0 +1,114 @@
+package steamgrid
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const previewTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>steamgrid preview</title>
+<style>
+body { font-family: sans-serif; background: #1b2838; color: #c7d5e0; margin: 2em; }
+table { border-collapse: collapse; }
+th { text-align: left; }
+td { padding: 8px; vertical-align: middle; border-top: 1px solid #2a475e; }
+img { max-width: 320px; max-height: 240px; background: #2a475e; }
+small { color: #8f98a0; }
+</style>
+</head>
+<body>
+<h1>steamgrid preview</h1>
+<p>{{len .Items}} images would change. Nothing was written to Steam, run again without --preview to apply them.</p>
+<table>
+<tr><th>Game</th><th>Art style</th><th>Current</th><th>New</th></tr>
+{{range .Items}}<tr>
+<td>{{.Game}}<br><small>{{.User}}, id {{.ID}}</small></td>
+<td>{{.ArtStyle}}<br><small>from {{.Source}}</small></td>
+<td>{{if .Current}}<img src="{{.Current}}">{{else}}<small>none</small>{{end}}</td>
+<td><img src="{{.New}}"></td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`
+
+// An image that would change, with the paths of the images relative to the
+// preview page.
+type previewItem struct {
+	User     string
+	Game     string
+	ID       string
+	ArtStyle string
+	Source   string
+	Current  string
+	New      string
+}
+
+// Preview page of a run, see --preview. The images are saved in a folder
+// next to the page, e.g. "preview_files" for "preview.html".
+type preview struct {
+	path  string
+	dir   string
+	Items []previewItem
+}
+
+func newPreview(path string) (*preview, error) {
+	dir := strings.TrimSuffix(path, filepath.Ext(path)) + "_files"
+	// Images from an older preview would pile up.
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	return &preview{path: path, dir: dir}, nil
+}
+
+// Adds the image that would be written for the game, next to the current one
+// in the grid dir. Images that wouldn't change are left out.
+func (p *preview) add(user User, game *Game, artStyle string, artStyleExtensions []string, imageBytes []byte, imageExt string) error {
+	name := user.SteamID32 + "-" + game.ID + artStyleExtensions[0]
+	item := previewItem{user.Name, game.Name, game.ID, artStyle, game.ImageSource, "", ""}
+
+	existing, _ := filepath.Glob(filepath.Join(user.GridDir, game.ID+artStyleExtensions[0]+".*"))
+	existing = filterForImages(existing)
+	if len(existing) > 0 {
+		currentBytes, err := ioutil.ReadFile(longPath(existing[0]))
+		if err == nil {
+			if imageHash(currentBytes) == imageHash(imageBytes) {
+				return nil
+			}
+			item.Current = filepath.Base(p.dir) + "/" + name + "-current" + filepath.Ext(existing[0])
+			err = ioutil.WriteFile(filepath.Join(p.dir, name+"-current"+filepath.Ext(existing[0])), currentBytes, 0666)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	item.New = filepath.Base(p.dir) + "/" + name + "-new" + imageExt
+	err := ioutil.WriteFile(filepath.Join(p.dir, name+"-new"+imageExt), imageBytes, 0666)
+	if err != nil {
+		return err
+	}
+	p.Items = append(p.Items, item)
+	return nil
+}
+
+// Writes the preview page.
+func (p *preview) write() error {
+	file, err := os.Create(p.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return template.Must(template.New("preview").Parse(previewTemplate)).Execute(file, p)
+}
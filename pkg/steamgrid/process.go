@@ -0,0 +1,85 @@
+package steamgrid
+
+import (
+	"context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Returns true if a Steam client process is running. Writing grid images
+// while Steam is running leads to stale caches and locked files on Windows.
+func isSteamRunning() bool {
+	switch runtime.GOOS {
+	case "windows":
+		output, err := exec.Command("tasklist", "/FI", "IMAGENAME eq steam.exe", "/NH").Output()
+		return err == nil && strings.Contains(strings.ToLower(string(output)), "steam.exe")
+	case "darwin":
+		return exec.Command("pgrep", "-x", "steam_osx").Run() == nil
+	default:
+		processes, err := filepath.Glob("/proc/[0-9]*/comm")
+		if err != nil {
+			return false
+		}
+		for _, process := range processes {
+			name, err := ioutil.ReadFile(process)
+			if err == nil && strings.TrimSpace(string(name)) == "steam" {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Returns the command that runs the Steam client with the given arguments.
+func steamCommand(installationDir string, args ...string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command(filepath.Join(installationDir, "steam.exe"), args...)
+	case "darwin":
+		return exec.Command("open", append([]string{"-a", "Steam", "--args"}, args...)...)
+	default:
+		return exec.Command("steam", args...)
+	}
+}
+
+// How long Steam may take to exit after being asked to shut down.
+const steamShutdownTimeout = 2 * time.Minute
+
+// How long --wait-steam waits for the user to close Steam.
+const steamWaitTimeout = 30 * time.Minute
+
+// Blocks until Steam is not running anymore. Returns ErrTimeout if it's still
+// running after the timeout or the deadline of the context.
+func waitForSteamToClose(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for isSteamRunning() {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.Canceled {
+				return ctx.Err()
+			}
+			return ErrTimeout
+		case <-time.After(2 * time.Second):
+		}
+	}
+	return nil
+}
+
+// Asks Steam to shut down and waits until it's closed.
+func closeSteam(ctx context.Context, installationDir string) error {
+	err := steamCommand(installationDir, "-shutdown").Start()
+	if err != nil {
+		return err
+	}
+	return waitForSteamToClose(ctx, steamShutdownTimeout)
+}
+
+// Starts Steam again in the background.
+func startSteam(installationDir string) error {
+	return steamCommand(installationDir).Start()
+}
@@ -0,0 +1,184 @@
+package steamgrid
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress display for the main loop. Shows a single, constantly updated line
+// with the rate, estimated time remaining and per art style counters. In
+// plain mode it prints one line per event instead, which is better for logs.
+type Progress struct {
+	// Guards the counters, which are also read by the metrics endpoint.
+	mutex sync.Mutex
+	plain bool
+	start time.Time
+	total int
+	done  int
+	bytes int
+	// Art style -> number of images found and processed.
+	found     map[string]int
+	processed map[string]int
+	warnings  int
+	// Length of the last line drawn, so it can be fully overwritten.
+	lastLength int
+}
+
+// NewProgress returns a progress display, printing plain lines if plain is
+// set.
+func NewProgress(plain bool) *Progress {
+	return &Progress{plain: plain, start: time.Now(), found: map[string]int{}, processed: map[string]int{}}
+}
+
+// AddGames to the total number of games to process.
+func (p *Progress) AddGames(n int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.total += n
+}
+
+// GameProgress collects the messages about a single game and prints them
+// all at once when the game is finished, so games processed at the same time
+// don't mix their lines.
+type GameProgress struct {
+	*Progress
+	lines []string
+}
+
+// StartGame is called before processing each game, returning the progress
+// to report its events to.
+func (p *Progress) StartGame(name string) *GameProgress {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	g := &GameProgress{Progress: p}
+	if p.plain {
+		g.lines = append(g.lines, fmt.Sprintf("Processing %v (%v/%v)", name, p.done+1, p.total))
+	}
+	return g
+}
+
+// FinishGame is called after processing each game, printing its messages.
+func (g *GameProgress) FinishGame() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.clear()
+	for _, line := range g.lines {
+		fmt.Println(line)
+	}
+	g.lines = nil
+	g.done++
+	g.draw()
+}
+
+// Found an image for the art style.
+func (g *GameProgress) Found(artStyle string, source string) {
+	g.count(artStyle, true)
+	g.Info("%v found from %v", artStyle, source)
+}
+
+// NotFound any image for the art style.
+func (g *GameProgress) NotFound(artStyle string) {
+	g.count(artStyle, false)
+	g.Info("%v not found", artStyle)
+}
+
+// Info keeps a message for the end of the game, only in plain mode.
+func (g *GameProgress) Info(format string, a ...interface{}) {
+	if g.plain {
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		g.lines = append(g.lines, fmt.Sprintf(format, a...))
+	}
+}
+
+// Warn keeps a message for the end of the game, in both modes.
+func (g *GameProgress) Warn(format string, a ...interface{}) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.warnings++
+	g.lines = append(g.lines, fmt.Sprintf(format, a...))
+}
+
+// Counts an art style as processed, and as found if found is set.
+func (p *Progress) count(artStyle string, found bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if found {
+		p.found[artStyle]++
+	}
+	p.processed[artStyle]++
+}
+
+// Downloaded n bytes.
+func (p *Progress) Downloaded(n int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.bytes += n
+}
+
+// Info prints a message only in plain mode.
+func (p *Progress) Info(format string, a ...interface{}) {
+	if p.plain {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		fmt.Printf(format+"\n", a...)
+	}
+}
+
+// Warn prints a message in both modes, above the progress line.
+func (p *Progress) Warn(format string, a ...interface{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.warnings++
+	p.clear()
+	fmt.Printf(format+"\n", a...)
+	p.draw()
+}
+
+// Done clears the progress line for the final report.
+func (p *Progress) Done() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.clear()
+}
+
+func (p *Progress) clear() {
+	if !p.plain && p.lastLength > 0 {
+		fmt.Print("\r" + strings.Repeat(" ", p.lastLength) + "\r")
+		p.lastLength = 0
+	}
+}
+
+func (p *Progress) draw() {
+	if p.plain || p.total == 0 {
+		return
+	}
+
+	elapsed := time.Since(p.start)
+	rate := float64(p.done) / elapsed.Seconds()
+	eta := "?"
+	if rate > 0 {
+		eta = (time.Duration(float64(p.total-p.done)/rate) * time.Second).Round(time.Second).String()
+	}
+
+	const width = 20
+	filled := width * p.done / p.total
+	line := fmt.Sprintf("[%v%v] %v/%v games, %.1f games/s, ETA %v, %.1f MB", strings.Repeat("#", filled), strings.Repeat(".", width-filled), p.done, p.total, rate, eta, float64(p.bytes)/1e6)
+	var artStyles []string
+	for artStyle := range p.processed {
+		artStyles = append(artStyles, artStyle)
+	}
+	sortArtStyles(artStyles)
+	for _, artStyle := range artStyles {
+		line += fmt.Sprintf(", %v %v/%v", artStyle, p.found[artStyle], p.processed[artStyle])
+	}
+
+	padding := ""
+	if len(line) < p.lastLength {
+		padding = strings.Repeat(" ", p.lastLength-len(line))
+	}
+	fmt.Print("\r" + line + padding)
+	p.lastLength = len(line)
+}
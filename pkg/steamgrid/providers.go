@@ -0,0 +1,188 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// ImageProvider is a source of artwork. Forks can add new sources by
+// implementing it and calling RegisterImageProvider.
+type ImageProvider interface {
+	// Name of the source, shown in the report (e.g. "SteamGridDB").
+	Name() string
+	// Search returns the candidate image URLs for a game and art style, best
+	// first. Returns no candidates if the provider doesn't support the style.
+	Search(game *Game, artStyle string, artStyleExtensions []string) ([]string, error)
+}
+
+// Providers registered from outside, tried after the built-in ones but before
+// falling back to a Google search.
+var externalProviders []ImageProvider
+
+// RegisterImageProvider adds a provider to be used in all following downloads.
+func RegisterImageProvider(provider ImageProvider) {
+	externalProviders = append(externalProviders, provider)
+}
+
+// Returns all providers to be used, in order of preference.
+func getImageProviders(options Options) []ImageProvider {
+	var providers []ImageProvider
+	if !options.SkipSteam {
+		providers = append(providers, steamProvider{newHTTPClient(providerTimeouts["steam"])})
+	}
+	if options.SteamGridDBApiKey != "" {
+		providers = append(providers, steamGridDBProvider{newHTTPClient(providerTimeouts["steamgriddb"]), options.SteamGridDBApiKey, options.Candidates})
+	}
+	if options.IGDBClient != "" && options.IGDBSecret != "" {
+		providers = append(providers, igdbProvider{newHTTPClient(providerTimeouts["igdb"]), options.IGDBSecret, options.IGDBClient})
+	}
+	if options.ScreenScraperDevID != "" && options.ScreenScraperDevPassword != "" {
+		providers = append(providers, screenScraperProvider{newHTTPClient(providerTimeouts["screenscraper"]), options.ScreenScraperDevID, options.ScreenScraperDevPassword, options.ScreenScraperUser, options.ScreenScraperPassword})
+	}
+	if !options.SkipStores {
+		storesClient := newHTTPClient(providerTimeouts["stores"])
+		providers = append(providers, gogProvider{storesClient}, epicProvider{storesClient})
+	}
+	providers = append(providers, externalProviders...)
+	if !options.SkipGoogle {
+		providers = append(providers, searchProvider{newHTTPClient(providerTimeouts["search"]), options.SearchEngine, options.SearchSize == "larger"})
+	}
+	return providers
+}
+
+// Official images from the Steam CDNs.
+type steamProvider struct {
+	client *http.Client
+}
+
+func (steamProvider) Name() string { return "steam server" }
+
+func (steamProvider) Search(game *Game, artStyle string, artStyleExtensions []string) ([]string, error) {
+	if artStyleExtensions[2] == "" {
+		return nil, nil
+	}
+	return []string{
+		fmt.Sprintf(akamaiURLFormat+artStyleExtensions[2], game.ID),
+		fmt.Sprintf(steamCdnURLFormat+artStyleExtensions[2], game.ID),
+	}, nil
+}
+
+type steamGridDBProvider struct {
+	client *http.Client
+	apiKey string
+	// Number of results kept for --candidates, 0 to keep none.
+	candidates int
+}
+
+func (steamGridDBProvider) Name() string { return "SteamGridDB" }
+
+func (p steamGridDBProvider) Search(game *Game, artStyle string, artStyleExtensions []string) ([]string, error) {
+	if _, ok := pairedArtStyles[artStyle]; ok {
+		url, err := getPairedSteamGridDBImage(p.client, game, artStyle, p.apiKey)
+		if err != nil {
+			return nil, err
+		} else if url != "" {
+			if p.candidates > 0 {
+				// The pair comes from another query, so the candidates
+				// need their own.
+				images, ambiguous, err := getSteamGridDBImages(p.client, game, artStyleExtensions, p.apiKey, p.candidates)
+				if err == nil {
+					keepSteamGridDBResult(game, artStyleExtensions, images, ambiguous)
+				}
+			}
+			return []string{url}, nil
+		}
+	}
+	url, err := getVerifiedSteamGridDBImage(p.client, game, artStyle, artStyleExtensions, p.apiKey, p.candidates)
+	if err != nil || url == "" {
+		return nil, err
+	}
+	return []string{url}, nil
+}
+
+type igdbProvider struct {
+	client   *http.Client
+	secret   string
+	clientID string
+}
+
+func (igdbProvider) Name() string { return "IGDB" }
+
+func (p igdbProvider) Search(game *Game, artStyle string, artStyleExtensions []string) ([]string, error) {
+	// IGDB has mostly cover styles
+	if artStyle != "Cover" {
+		return nil, nil
+	}
+	url, err := getIGDBImage(p.client, game.Name, p.secret, p.clientID)
+	if err != nil || url == "" {
+		return nil, err
+	}
+	return []string{url}, nil
+}
+
+// Image search engines supported as a last resort.
+// With larger set, they may return images bigger than the art style, which
+// are cropped to fit after the download.
+var searchEngines = map[string]func(client *http.Client, gameName string, artStyleExtensions []string, larger bool) (string, error){
+	"google":     getGoogleImage,
+	"bing":       getBingImage,
+	"duckduckgo": getDuckDuckGoImage,
+}
+
+// Web image search with one of the searchEngines.
+type searchProvider struct {
+	client *http.Client
+	engine string
+	larger bool
+}
+
+func (searchProvider) Name() string { return "search" }
+
+func (p searchProvider) Search(game *Game, artStyle string, artStyleExtensions []string) ([]string, error) {
+	// Skip for Covers, bad results
+	if artStyle != "Banner" {
+		return nil, nil
+	}
+	url, err := searchEngines[p.engine](p.client, game.Name, artStyleExtensions, p.larger)
+	if err != nil || url == "" {
+		return nil, err
+	}
+	return []string{url}, nil
+}
+
+// CheckSteamGridDBKey checks the SteamGridDB api key with a request for a
+// well known game.
+func CheckSteamGridDBKey(key string) error {
+	_, err := steamGridDBGetRequest(httpClient, steamGridDBBaseURL+"/grids/steam/220", key)
+	if err != nil && err.Error() == "401" {
+		return errSteamGridDBAuth
+	} else if err != nil && err.Error() != "404" {
+		return err
+	}
+	return nil
+}
+
+// CheckIGDBKeys checks the IGDB client id and secret by asking Twitch for a
+// token.
+func CheckIGDBKeys(client string, secret string) error {
+	response, err := httpClient.Post("https://id.twitch.tv/oauth2/token?client_id="+client+"&client_secret="+secret+"&grant_type=client_credentials", "", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.Unmarshal(responseBytes, &token)
+	if response.StatusCode != 200 || token.AccessToken == "" {
+		return errors.New("IGDB rejected the client id or secret")
+	}
+	return nil
+}
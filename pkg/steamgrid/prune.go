@@ -0,0 +1,99 @@
+package steamgrid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Removes old backups from the grid directory, keeping the latest keep
+// backups of each game and art style, and all backups of games that are not
+// in the library anymore and have no grid image left. Backups the manifest
+// still uses are always kept. Returns the removed paths.
+func pruneBackups(gridDir string, games map[string]*Game, keep int) ([]string, error) {
+	backups, err := filepath.Glob(filepath.Join(originalsDir(gridDir), "* *.*"))
+	if err != nil {
+		return nil, err
+	}
+
+	// "<gameID><suffix>" -> backups, e.g. "440p" for the covers of TF2.
+	groups := map[string][]string{}
+	for _, backup := range filterForImages(backups) {
+		name := filepath.Base(backup)
+		key := name[:strings.LastIndex(name, " ")]
+		groups[key] = append(groups[key], backup)
+	}
+
+	inUse := map[string]bool{}
+	for _, entry := range LoadManifest(gridDir).Entries {
+		inUse[entry.Backup] = true
+	}
+
+	var removed []string
+	for key, paths := range groups {
+		sort.Slice(paths, func(i, j int) bool {
+			return modTime(paths[i]) > modTime(paths[j])
+		})
+		if !isOrphanBackup(gridDir, key, games) {
+			if len(paths) <= keep {
+				continue
+			}
+			paths = paths[keep:]
+		}
+		for _, path := range paths {
+			if inUse[filepath.Base(path)] {
+				continue
+			}
+			err = os.Remove(path)
+			if err != nil {
+				return removed, err
+			}
+			removed = append(removed, path)
+		}
+	}
+	return removed, nil
+}
+
+// Returns true if the backups under key belong to a game that is not in the
+// library and has no grid image anymore.
+func isOrphanBackup(gridDir string, key string, games map[string]*Game) bool {
+	gameID := strings.TrimRightFunc(key, func(r rune) bool { return r < '0' || r > '9' })
+	if _, ok := games[gameID]; ok {
+		return false
+	}
+	images, _ := filepath.Glob(filepath.Join(gridDir, key+".*"))
+	return len(filterForImages(images)) == 0
+}
+
+// Returns the modification time of the file in nanoseconds, 0 on errors.
+func modTime(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().UnixNano()
+}
+
+// PruneBackups removes old and orphaned backups for all users.
+func PruneBackups(options Options, keep int) error {
+	if keep < 1 {
+		return errors.New("At least one backup of each image must be kept, or they can't be restored")
+	}
+	users, err := LoadUsers(options)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		gridDir := user.GridDir
+		removed, err := pruneBackups(gridDir, GetGames(user, false, ""), keep)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %v backups for %v.\n", len(removed), user.Name)
+	}
+	return nil
+}
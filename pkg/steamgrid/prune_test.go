@@ -0,0 +1,136 @@
+package steamgrid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPruneBackups(t *testing.T) {
+	tests := []struct {
+		name string
+		// Games in the library.
+		games []string
+		// Backups, oldest first.
+		backups []string
+		// Grid images still there.
+		images []string
+		// Backups the manifest uses.
+		inManifest []string
+		keep       int
+		removed    []string
+	}{
+		{
+			name:    "keeps the latest",
+			games:   []string{"10"},
+			backups: []string{"10p a.png", "10p b.png", "10p c.png"},
+			keep:    2,
+			removed: []string{"10p a.png"},
+		},
+		{
+			name:    "groups by art style",
+			games:   []string{"10"},
+			backups: []string{"10p a.png", "10_hero b.png", "10p c.png"},
+			keep:    1,
+			removed: []string{"10p a.png"},
+		},
+		{
+			name:       "keeps the ones in the manifest",
+			games:      []string{"10"},
+			backups:    []string{"10p a.png", "10p b.png", "10p c.png"},
+			inManifest: []string{"10p a.png"},
+			keep:       1,
+			removed:    []string{"10p b.png"},
+		},
+		{
+			name:    "removes all of orphans",
+			games:   []string{"10"},
+			backups: []string{"20p a.png", "20p b.png"},
+			keep:    1,
+			removed: []string{"20p a.png", "20p b.png"},
+		},
+		{
+			name:    "keeps games with a grid image",
+			backups: []string{"20p a.png", "20p b.png"},
+			images:  []string{"20p.png"},
+			keep:    1,
+			removed: []string{"20p a.png"},
+		},
+		{
+			name:       "keeps orphans in the manifest",
+			backups:    []string{"20p a.png", "20p b.png"},
+			inManifest: []string{"20p b.png"},
+			keep:       1,
+			removed:    []string{"20p a.png"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gridDir, err := ioutil.TempDir("", "steamgrid")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(gridDir)
+			err = os.Mkdir(originalsDir(gridDir), 0777)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			modified := time.Now().Add(-time.Hour)
+			for _, name := range test.backups {
+				path := filepath.Join(originalsDir(gridDir), name)
+				err := ioutil.WriteFile(path, []byte(name), 0666)
+				if err == nil {
+					err = os.Chtimes(path, modified, modified)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				modified = modified.Add(time.Minute)
+			}
+			for _, name := range test.images {
+				err := ioutil.WriteFile(filepath.Join(gridDir, name), []byte(name), 0666)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			manifest := LoadManifest(gridDir)
+			for _, backup := range test.inManifest {
+				manifest.Entries[backup] = &ManifestEntry{Backup: backup}
+			}
+			err = manifest.Save()
+			if err != nil {
+				t.Fatal(err)
+			}
+			games := map[string]*Game{}
+			for _, id := range test.games {
+				games[id] = &Game{ID: id}
+			}
+
+			removedPaths, err := pruneBackups(gridDir, games, test.keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var removed []string
+			for _, path := range removedPaths {
+				removed = append(removed, filepath.Base(path))
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("%v still exists", path)
+				}
+			}
+			sort.Strings(removed)
+			if len(removed) != len(test.removed) {
+				t.Fatalf("removed %v, expected %v", removed, test.removed)
+			}
+			for i := range removed {
+				if removed[i] != test.removed[i] {
+					t.Fatalf("removed %v, expected %v", removed, test.removed)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package steamgrid
+
+import (
+	"strings"
+)
+
+// Returns true if the image should be downloaded again because of --refresh,
+// a comma separated list of sources like "steamgriddb,igdb" or "all". Images
+// recorded in the manifest match by their source, and "all" also matches
+// backups and manual customizations. Images from the overrides folder are
+// never refreshed.
+func shouldRefresh(refresh string, game *Game, entry *ManifestEntry) bool {
+	if refresh == "" || game.ImageSource == "" || strings.HasPrefix(game.ImageSource, "local file") {
+		return false
+	}
+	for _, source := range strings.Split(refresh, ",") {
+		source = strings.TrimSpace(source)
+		if strings.EqualFold(source, "all") {
+			return true
+		}
+		if entry == nil {
+			continue
+		}
+		// "steam" matches "steam server".
+		words := strings.Fields(entry.Source)
+		if strings.EqualFold(source, entry.Source) || (len(words) > 0 && strings.EqualFold(source, words[0])) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package steamgrid
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// A game in a RetroArch playlist.
+type playlistRom struct {
+	// Lower-cased file name of the ROM, to find it in the launch options of
+	// a shortcut.
+	file   string
+	label  string
+	system string
+}
+
+// Entry of a playlist file, with the fields used here.
+type playlistItem struct {
+	Path  string
+	Label string
+}
+
+// Games of the RetroArch playlists, used to name and tag emulator shortcuts.
+var playlistRoms []playlistRom
+
+// Returns the folders where RetroArch keeps its playlists, including the
+// Flatpak one used by EmuDeck on the Steam Deck.
+func defaultPlaylistDirs() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	switch runtime.GOOS {
+	case "windows":
+		return []string{`C:\RetroArch-Win64\playlists`, filepath.Join(os.Getenv("APPDATA"), "RetroArch", "playlists")}
+	case "darwin":
+		return []string{filepath.Join(home, "Library", "Application Support", "RetroArch", "playlists")}
+	default:
+		return []string{
+			filepath.Join(home, ".config", "retroarch", "playlists"),
+			filepath.Join(home, ".var", "app", "org.libretro.RetroArch", "config", "retroarch", "playlists"),
+		}
+	}
+}
+
+// Reads the games of a playlist, in the JSON format or the older one with
+// six lines per game.
+func readPlaylist(path string) ([]playlistRom, error) {
+	playlistBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// Playlists are named after their system, e.g. "Sega - Mega Drive -
+	// Genesis.lpl".
+	system := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	var items []playlistItem
+	var playlist struct {
+		Items []playlistItem
+	}
+	if json.Unmarshal(playlistBytes, &playlist) == nil {
+		items = playlist.Items
+	} else {
+		lines := strings.Split(strings.ReplaceAll(string(playlistBytes), "\r\n", "\n"), "\n")
+		for i := 0; i+1 < len(lines); i += 6 {
+			items = append(items, playlistItem{lines[i], lines[i+1]})
+		}
+	}
+
+	var roms []playlistRom
+	for _, item := range items {
+		// Games in archives are "archive.zip#game.sfc".
+		romPath := strings.SplitN(item.Path, "#", 2)[0]
+		file := strings.ToLower(filepath.Base(filepath.FromSlash(strings.ReplaceAll(romPath, `\`, "/"))))
+		if file == "" || file == "." || item.Label == "" {
+			continue
+		}
+		roms = append(roms, playlistRom{file, item.Label, system})
+	}
+	return roms, nil
+}
+
+// Loads the RetroArch playlists in the folder, or in the default folders if
+// it's empty. Playlists in the default folders that can't be read are
+// skipped.
+func loadPlaylists(dir string) error {
+	playlistRoms = nil
+	explicit := dir != ""
+	dirs := []string{dir}
+	if !explicit {
+		dirs = defaultPlaylistDirs()
+	}
+	for _, dir := range dirs {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.lpl"))
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 && explicit {
+			return errors.New("No RetroArch playlists in " + dir)
+		}
+		for _, path := range paths {
+			roms, err := readPlaylist(path)
+			if err != nil && explicit {
+				return err
+			}
+			playlistRoms = append(playlistRoms, roms...)
+		}
+	}
+	return nil
+}
+
+// Names emulator shortcuts after the title in the RetroArch playlists and
+// tags them with their system, so they get better search results and the
+// system's overlay. Shortcuts are matched by the ROM in their target or
+// launch options, like the ones made by EmuDeck and Steam ROM Manager.
+func addPlaylistNames(user User, games map[string]*Game) {
+	if len(playlistRoms) == 0 {
+		return
+	}
+	shortcutBytes, err := ioutil.ReadFile(filepath.Join(findInsensitive(user.Dir, "config"), "shortcuts.vdf"))
+	if err != nil {
+		return
+	}
+	root, err := parseVdf(shortcutBytes)
+	if err != nil {
+		return
+	}
+	shortcuts := root.child("shortcuts")
+	if shortcuts == nil {
+		return
+	}
+
+	for _, shortcut := range shortcuts.Children {
+		name, exe, launchOptions := shortcut.child("AppName"), shortcut.child("Exe"), shortcut.child("LaunchOptions")
+		if name == nil || exe == nil || launchOptions == nil {
+			continue
+		}
+		var gameID uint32
+		if appID := shortcut.child("appid"); appID != nil && len(appID.Value) == 4 {
+			gameID = binary.LittleEndian.Uint32(appID.Value)
+		} else {
+			gameID = shortcutLegacyID(exe.Value, name.Value)
+		}
+		game, ok := games[fmt.Sprint(gameID)]
+		if !ok {
+			continue
+		}
+
+		target := strings.ToLower(strings.ReplaceAll(string(exe.Value)+" "+string(launchOptions.Value), `\`, "/"))
+		for _, rom := range playlistRoms {
+			if strings.Contains(target, "/"+rom.file) || strings.Contains(target, `"`+rom.file) {
+				game.Name = rom.label
+				if !hasTag(game, rom.system) {
+					game.Tags = append(game.Tags, rom.system)
+				}
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package steamgrid
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Folder inside the run snapshots folder keeping the images of the recorded
+// runs, named by hash, so --rollback can put them back.
+const runImagesDir = "images"
+
+// Returns the folder with the retained images of the grid folder. With
+// --backup-dir they're kept there, out of the grid folder, like the backups.
+func retainedImagesDir(gridDir string) string {
+	if backupRoot == "" {
+		return filepath.Join(gridDir, runSnapshotsDir, runImagesDir)
+	}
+	return filepath.Join(backupRoot, runSnapshotsDir, filepath.Base(originalsDir(gridDir)))
+}
+
+// Reads a retained image, also looking in the grid folder, where they were
+// kept before --backup-dir was given.
+func readRetained(gridDir string, name string) ([]byte, error) {
+	fileBytes, err := ioutil.ReadFile(filepath.Join(retainedImagesDir(gridDir), name))
+	if err != nil && backupRoot != "" {
+		return ioutil.ReadFile(filepath.Join(gridDir, runSnapshotsDir, runImagesDir, name))
+	}
+	return fileBytes, err
+}
+
+// Keeps hard links to the grid images in the manifest and their backups
+// that aren't retained yet, so they take no extra space. File systems
+// without hard links, like FAT, would need a full copy of the grid folder,
+// so there the images are only copied by retainRemoved when they change.
+func retainRunImages(gridDir string, entries map[string]*ManifestEntry) {
+	for key, entry := range entries {
+		retainFile(gridDir, filepath.Join(gridDir, key+entry.ImageExt), entry.Hash+entry.ImageExt, false)
+		if entry.Backup != "" {
+			retainFile(gridDir, filepath.Join(originalsDir(gridDir), entry.Backup), entry.Backup, false)
+		}
+	}
+}
+
+// Keeps a copy of a grid image or backup that is about to be removed or
+// replaced, if runs are recorded, so --rollback can still put it back.
+func retainRemoved(gridDir string, path string, isBackup bool) {
+	if _, err := os.Stat(filepath.Join(gridDir, runSnapshotsDir)); err != nil {
+		return
+	}
+	name := filepath.Base(path)
+	if !isBackup {
+		imageBytes, err := ioutil.ReadFile(longPath(path))
+		if err != nil {
+			return
+		}
+		name = imageHash(imageBytes) + filepath.Ext(path)
+	}
+	retainFile(gridDir, path, name, true)
+}
+
+// Keeps a hard link to the file under the given name in the retained
+// images, or a copy if hard links fail and copyFallback is set.
+func retainFile(gridDir string, path string, name string, copyFallback bool) {
+	dir := retainedImagesDir(gridDir)
+	retained := filepath.Join(dir, name)
+	if _, err := os.Stat(retained); err == nil {
+		return
+	}
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// Removed since it was written.
+		return
+	}
+	if os.MkdirAll(dir, 0777) != nil {
+		return
+	}
+	if os.Link(longPath(path), longPath(retained)) != nil && copyFallback {
+		fileBytes, err := ioutil.ReadFile(longPath(path))
+		if err == nil {
+			writeFileAtomic(retained, fileBytes, 0666)
+		}
+	}
+}
+
+// Removes the retained images that no run snapshot uses anymore.
+func pruneRunImages(gridDir string) {
+	used := map[string]bool{}
+	for _, name := range listRunSnapshots(gridDir) {
+		entries, err := loadRunSnapshot(gridDir, name)
+		if err != nil {
+			// Better keep everything than lose what this run needs.
+			return
+		}
+		for _, entry := range entries {
+			used[entry.Hash+entry.ImageExt] = true
+			used[entry.Backup] = true
+		}
+	}
+	paths, _ := filepath.Glob(filepath.Join(retainedImagesDir(gridDir), "*"))
+	for _, path := range paths {
+		if !used[filepath.Base(path)] {
+			os.Remove(path)
+		}
+	}
+}
+
+// Returns the latest run snapshot whose name starts with the given time,
+// e.g. "2024-05-01" or "2024-05-01T20-15".
+func findRunSnapshot(gridDir string, runTime string) (string, bool) {
+	snapshots := listRunSnapshots(gridDir)
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if strings.HasPrefix(snapshots[i], runTime) {
+			return snapshots[i], true
+		}
+	}
+	return "", false
+}
+
+// Removes the grid images with the given key, whatever their extension.
+func removeGridImages(gridDir string, key string) error {
+	images, _ := filepath.Glob(filepath.Join(gridDir, key+".*"))
+	for _, path := range filterForImages(images) {
+		err := os.Remove(longPath(path))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback puts the grid images of all users back to how the run at the
+// given time left them. Images steamgrid wrote after that run are removed,
+// other images are left alone.
+func Rollback(options Options, runTime string) error {
+	users, err := LoadUsers(options)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, user := range users {
+		gridDir := user.GridDir
+		name, ok := findRunSnapshot(gridDir, runTime)
+		if !ok {
+			continue
+		}
+		found = true
+		entries, err := loadRunSnapshot(gridDir, name)
+		if err != nil {
+			return err
+		}
+		manifest := LoadManifest(gridDir)
+
+		restored, missing := 0, 0
+		for key, entry := range entries {
+			path := filepath.Join(gridDir, key+entry.ImageExt)
+			current, ok := manifest.Entries[key]
+			if ok && current.Hash == entry.Hash && current.ImageExt == entry.ImageExt {
+				if _, err := os.Stat(path); err == nil {
+					continue
+				}
+			}
+			imageBytes, err := readRetained(gridDir, entry.Hash+entry.ImageExt)
+			if err != nil {
+				fmt.Printf("No copy of %v left, it will be downloaded again on the next run.\n", path)
+				missing++
+				continue
+			}
+			if ok && !options.NoBackup {
+				// Keeps the newer image, to roll forward again.
+				retainRemoved(gridDir, filepath.Join(gridDir, key+current.ImageExt), false)
+			}
+			if ok && current.ImageExt != entry.ImageExt {
+				os.Remove(filepath.Join(gridDir, key+current.ImageExt))
+			}
+			err = writeFileAtomic(path, imageBytes, 0666)
+			if err != nil {
+				return err
+			}
+			// Older clients and Big Picture mode show the copies.
+			if ok {
+				for _, key := range current.Copies {
+					err = removeGridImages(gridDir, key)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			for _, key := range entry.Copies {
+				err = removeGridImages(gridDir, key)
+				if err == nil {
+					err = writeFileAtomic(filepath.Join(gridDir, key+entry.ImageExt), imageBytes, 0666)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			// Without its backup, the next run would take the image for one
+			// set by hand.
+			if entry.Backup != "" {
+				backupBytes, err := readRetained(gridDir, entry.Backup)
+				if err == nil {
+					err = writeFileAtomic(filepath.Join(originalsDir(gridDir), entry.Backup), backupBytes, 0666)
+				}
+				if err != nil {
+					fmt.Printf("Failed to restore the backup of %v: %v\n", path, err.Error())
+				}
+			}
+			restored++
+		}
+
+		removed := 0
+		for key, current := range manifest.Entries {
+			if _, ok := entries[key]; !ok {
+				if !options.NoBackup {
+					retainRemoved(gridDir, filepath.Join(gridDir, key+current.ImageExt), false)
+				}
+				err := os.Remove(filepath.Join(gridDir, key+current.ImageExt))
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				for _, copyKey := range current.Copies {
+					err = removeGridImages(gridDir, copyKey)
+					if err != nil {
+						return err
+					}
+				}
+				removed++
+			}
+		}
+
+		manifest.Entries = entries
+		for key := range entries {
+			if _, err := os.Stat(filepath.Join(gridDir, key+entries[key].ImageExt)); err != nil {
+				// Lost, so the next run downloads it again.
+				delete(manifest.Entries, key)
+			} else if entries[key].Source == manualCustomizationSource {
+				// Set by hand and recorded before a pick replaced it, the
+				// next run must not take it for one of ours.
+				delete(manifest.Entries, key)
+			}
+		}
+		err = manifest.Save()
+		if err != nil {
+			return err
+		}
+		// The rollback shows up in --diff like a run.
+		err = manifest.SaveSnapshot(time.Now(), !options.NoBackup)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%v: rolled back to the run of %v, %v images restored, %v removed, %v missing.\n", user.Name, name, restored, removed, missing)
+	}
+	if !found {
+		return errors.New("No run recorded at " + runTime + ", see the steamgrid-runs folder in the grid folder for the recorded runs")
+	}
+	return nil
+}
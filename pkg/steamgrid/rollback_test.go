@@ -0,0 +1,88 @@
+package steamgrid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Writes the image of a game and its copy like a run does, and records them
+// in the manifest.
+func writeRunImage(t *testing.T, gridDir string, manifest *Manifest, ext string, imageBytes []byte) {
+	for _, key := range []string{"10p", "999p"} {
+		err := removeGridImages(gridDir, key)
+		if err == nil {
+			err = ioutil.WriteFile(filepath.Join(gridDir, key+ext), imageBytes, 0666)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest.Entries["10p"] = &ManifestEntry{
+		Source:    "steam server",
+		ImageExt:  ext,
+		Hash:      imageHash(imageBytes),
+		CleanHash: imageHash(imageBytes),
+		Copies:    []string{"999p"},
+	}
+	err := manifest.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollbackRestoresImagesAndCopies(t *testing.T) {
+	steamDir, err := ioutil.TempDir("", "steamgrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(steamDir)
+	userDir := filepath.Join(steamDir, "userdata", "123")
+	gridDir := filepath.Join(userDir, "config", "grid")
+	err = os.MkdirAll(gridDir, 0777)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(userDir, "config", "localconfig.vdf"), []byte(`"PersonaName" "tester"`), 0666)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstRun := time.Date(2024, 5, 1, 20, 15, 0, 0, time.Local)
+	manifest := LoadManifest(gridDir)
+	writeRunImage(t, gridDir, manifest, ".png", []byte("first cover"))
+	err = manifest.SaveSnapshot(firstRun, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next run replaces the image with one of another format.
+	err = removeExisting(gridDir, "10", []string{"p"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRunImage(t, gridDir, manifest, ".jpg", []byte("second cover"))
+	err = manifest.SaveSnapshot(firstRun.Add(24*time.Hour), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Rollback(Options{SteamDir: steamDir}, "2024-05-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"10p", "999p"} {
+		imageBytes, err := ioutil.ReadFile(filepath.Join(gridDir, key+".png"))
+		if err != nil || string(imageBytes) != "first cover" {
+			t.Errorf("%v not rolled back: %q, %v", key, imageBytes, err)
+		}
+		if _, err := os.Stat(filepath.Join(gridDir, key+".jpg")); !os.IsNotExist(err) {
+			t.Errorf("%v of the later run not removed", key)
+		}
+	}
+	entry := LoadManifest(gridDir).Entries["10p"]
+	if entry == nil || entry.Hash != imageHash([]byte("first cover")) || entry.ImageExt != ".png" {
+		t.Errorf("manifest not rolled back: %+v", entry)
+	}
+}
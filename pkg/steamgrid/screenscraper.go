@@ -0,0 +1,103 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// https://www.screenscraper.fr/webapi2.php
+const screenScraperSearchURL = "https://www.screenscraper.fr/api2/jeuRecherche.php"
+
+// ScreenScraper media types for each art style.
+var screenScraperMediaTypes = map[string]string{
+	"Banner": "screenmarquee",
+	"Cover":  "box-2D",
+	"Hero":   "fanart",
+	"Logo":   "wheel",
+}
+
+// Returned when ScreenScraper rejects the credentials.
+var errScreenScraperAuth = errors.New("ScreenScraper credentials are missing or invalid")
+
+type screenScraperResponse struct {
+	Response struct {
+		Jeux []struct {
+			ID     string
+			Medias []struct {
+				Type   string
+				URL    string
+				Region string
+				Format string
+			}
+		}
+	}
+}
+
+// Artwork for emulated games, only used for non-Steam shortcuts.
+type screenScraperProvider struct {
+	client      *http.Client
+	devID       string
+	devPassword string
+	user        string
+	password    string
+}
+
+func (screenScraperProvider) Name() string { return "ScreenScraper" }
+
+func (p screenScraperProvider) Search(game *Game, artStyle string, artStyleExtensions []string) ([]string, error) {
+	mediaType, ok := screenScraperMediaTypes[artStyle]
+	if !game.Custom || !ok || game.Name == "" {
+		return nil, nil
+	}
+
+	query := url.Values{}
+	query.Set("devid", p.devID)
+	query.Set("devpassword", p.devPassword)
+	query.Set("softname", "steamgrid")
+	query.Set("output", "json")
+	query.Set("recherche", cleanRomName(game.Name))
+	if p.user != "" {
+		query.Set("ssid", p.user)
+		query.Set("sspassword", p.password)
+	}
+
+	response, err := p.client.Get(screenScraperSearchURL + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == 401 || response.StatusCode == 403 {
+		return nil, errScreenScraperAuth
+	} else if response.StatusCode >= 400 {
+		// Quota exceeded or game not found, try the other providers.
+		return nil, nil
+	}
+
+	var jsonResponse screenScraperResponse
+	err = json.Unmarshal(responseBytes, &jsonResponse)
+	if err != nil {
+		return nil, nil
+	}
+
+	var urls []string
+	for _, jeu := range jsonResponse.Response.Jeux {
+		for _, media := range jeu.Medias {
+			if media.Type == mediaType && media.URL != "" {
+				urls = append(urls, media.URL)
+			}
+		}
+		if len(urls) > 0 {
+			// Only use the best matching game.
+			break
+		}
+	}
+	return urls, nil
+}
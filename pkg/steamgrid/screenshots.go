@@ -0,0 +1,80 @@
+package steamgrid
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"io/ioutil"
+	"net/http"
+)
+
+// Source of images made from store screenshots, the last resort for games
+// without any artwork.
+const screenshotSource = "Steam screenshot"
+
+// Official store API, with only the screenshots of the app.
+const steamScreenshotsURL = "https://store.steampowered.com/api/appdetails?filters=screenshots&appids="
+
+type steamScreenshotsResponse map[string]struct {
+	Success bool
+	Data    struct {
+		Screenshots []struct {
+			PathFull string `json:"path_full"`
+		}
+	}
+}
+
+// Downloads the first store screenshot of a Steam game and crops it to the
+// aspect ratio of the art style. Returns nil if the game has no screenshots.
+func getScreenshotImage(client *http.Client, game *Game, artStyleExtensions []string) ([]byte, error) {
+	response, err := tryDownload(client, steamScreenshotsURL+game.ID)
+	if err != nil || response == nil {
+		return nil, err
+	}
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var jsonResponse steamScreenshotsResponse
+	err = json.Unmarshal(responseBytes, &jsonResponse)
+	if err != nil {
+		return nil, err
+	}
+	details, ok := jsonResponse[game.ID]
+	if !ok || !details.Success || len(details.Data.Screenshots) == 0 {
+		return nil, nil
+	}
+
+	response, err = tryDownload(client, details.Data.Screenshots[0].PathFull)
+	if err != nil || response == nil {
+		return nil, err
+	}
+	screenshot, _, err := image.Decode(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := searchDimensions(artStyleExtensions)
+	cropped := fitToSize(screenshot, width, height)
+
+	buf := new(bytes.Buffer)
+	err = encodeJPEG(buf, cropped)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Returns the largest centered rectangle inside bounds with the aspect ratio
+// width:height.
+func cropToAspect(bounds image.Rectangle, width int, height int) image.Rectangle {
+	cropWidth, cropHeight := bounds.Dx(), bounds.Dx()*height/width
+	if cropHeight > bounds.Dy() {
+		cropWidth, cropHeight = bounds.Dy()*width/height, bounds.Dy()
+	}
+	min := bounds.Min.Add(image.Point{(bounds.Dx() - cropWidth) / 2, (bounds.Dy() - cropHeight) / 2})
+	return image.Rectangle{min, min.Add(image.Point{cropWidth, cropHeight})}
+}
@@ -0,0 +1,250 @@
+package steamgrid
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const serveIndexTemplate = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>steamgrid</title></head>
+<body>
+<h1>steamgrid</h1>
+<p>Pick a game to review its artwork.</p>
+<ul>
+{{range .}}<li><a href="/game?id={{.ID}}">{{if .Name}}{{.Name}}{{else}}unknown game with id {{.ID}}{{end}}</a></li>
+{{end}}</ul>
+</body></html>`
+
+const serveGameTemplate = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Game.Name}} - steamgrid</title>
+<style>
+img { max-height: 200px; max-width: 400px; margin: 4px; }
+form { display: inline-block; }
+button { border: none; background: none; padding: 0; cursor: pointer; }
+</style></head>
+<body>
+<p><a href="/">All games</a></p>
+<h1>{{.Game.Name}} ({{.Game.ID}})</h1>
+{{$id := .Game.ID}}
+{{$token := .Token}}
+{{range .Styles}}
+<h2>{{.Name}}</h2>
+<p>Current: {{if .HasCurrent}}<img src="/current?id={{$id}}&style={{.Name}}">{{else}}none{{end}}</p>
+<p>Click a candidate to use it:</p>
+{{$style := .Name}}
+{{range .Candidates}}
+<form method="post" action="/pick">
+<input type="hidden" name="id" value="{{$id}}">
+<input type="hidden" name="style" value="{{$style}}">
+<input type="hidden" name="url" value="{{.URL}}">
+<input type="hidden" name="token" value="{{$token}}">
+<button type="submit" title="{{.Provider}}"><img src="{{.Thumb}}"></button>
+</form>
+{{else}}<p>No candidates found.</p>{{end}}
+{{end}}
+</body></html>`
+
+type serveStyle struct {
+	Name       string
+	HasCurrent bool
+	Candidates []artworkCandidate
+}
+
+// Web interface to review the artwork of each game and pick among the
+// candidates of all providers.
+type artworkServer struct {
+	*artworkBrowser
+	index    *template.Template
+	gamePage *template.Template
+	// Keeps other web pages from picking artwork.
+	guard *FormGuard
+	// Candidates last shown for each game and art style, the only URLs a
+	// pick may download.
+	mutex  sync.Mutex
+	listed map[string][]artworkCandidate
+}
+
+// Serve the web interface on the given address until it fails.
+func Serve(addr string, options Options) error {
+	browser, err := newArtworkBrowser(options)
+	if err != nil {
+		return err
+	}
+	guard, err := NewFormGuard()
+	if err != nil {
+		return err
+	}
+	server := &artworkServer{
+		artworkBrowser: browser,
+		index:          template.Must(template.New("index").Parse(serveIndexTemplate)),
+		gamePage:       template.Must(template.New("game").Parse(serveGameTemplate)),
+		guard:          guard,
+		listed:         map[string][]artworkCandidate{},
+	}
+
+	// Only this computer, unless a host is given.
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		addr = net.JoinHostPort("localhost", port)
+	}
+
+	// Not the default mux, which has the --pprof handlers.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", server.handleIndex)
+	mux.HandleFunc("/game", server.handleGame)
+	mux.HandleFunc("/current", server.handleCurrent)
+	mux.HandleFunc("/pick", server.handlePick)
+	fmt.Printf("Serving the artwork review on http://%v/\n", strings.Replace(addr, "0.0.0.0", "localhost", 1))
+	return http.ListenAndServe(addr, mux)
+}
+
+func (server *artworkServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	server.index.Execute(w, server.sortedGames())
+}
+
+// Returns the game and art style of a request, or an error for the client.
+func (server *artworkServer) gameAndStyle(r *http.Request) (*Game, string, error) {
+	game, ok := server.games[r.FormValue("id")]
+	if !ok {
+		return nil, "", errors.New("Unknown game")
+	}
+	artStyle := r.FormValue("style")
+	if _, ok := server.artStyles[artStyle]; artStyle != "" && !ok {
+		return nil, "", errors.New("Unknown art style")
+	}
+	return game, artStyle, nil
+}
+
+func (server *artworkServer) handleGame(w http.ResponseWriter, r *http.Request) {
+	game, _, err := server.gameAndStyle(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var styles []serveStyle
+	for _, artStyle := range server.sortedArtStyles() {
+		candidates := server.candidates(game, artStyle)
+		server.mutex.Lock()
+		server.listed[game.ID+"/"+artStyle] = candidates
+		server.mutex.Unlock()
+		styles = append(styles, serveStyle{
+			Name:       artStyle,
+			HasCurrent: server.currentImagePath(game, artStyle) != "",
+			Candidates: candidates,
+		})
+	}
+
+	server.gamePage.Execute(w, struct {
+		Game   *Game
+		Styles []serveStyle
+		Token  string
+	}{game, styles, server.guard.Token()})
+}
+
+func (server *artworkServer) handleCurrent(w http.ResponseWriter, r *http.Request) {
+	game, artStyle, err := server.gameAndStyle(r)
+	if err != nil || artStyle == "" {
+		http.NotFound(w, r)
+		return
+	}
+	path := server.currentImagePath(game, artStyle)
+	if path == "" {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, path)
+}
+
+func (server *artworkServer) handlePick(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !server.guard.Allows(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	game, artStyle, err := server.gameAndStyle(r)
+	if err != nil || artStyle == "" {
+		http.Error(w, "Unknown game or art style", http.StatusBadRequest)
+		return
+	}
+	pickedURL := r.FormValue("url")
+	if !server.wasListed(game, artStyle, pickedURL) {
+		http.Error(w, "Not one of the candidates", http.StatusBadRequest)
+		return
+	}
+
+	err = server.pick(game, artStyle, pickedURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/game?id="+game.ID, http.StatusSeeOther)
+}
+
+// Protects the forms of a local web page from other web pages, which could
+// post to them from the same browser.
+type FormGuard struct {
+	// Random token put in the forms.
+	token string
+}
+
+// NewFormGuard returns a guard with a new random token.
+func NewFormGuard() (*FormGuard, error) {
+	tokenBytes := make([]byte, 16)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &FormGuard{hex.EncodeToString(tokenBytes)}, nil
+}
+
+// Token returns the value of the hidden "token" field of the forms.
+func (guard *FormGuard) Token() string {
+	return guard.token
+}
+
+// Allows returns true if the request was posted by a form of the page: it
+// has the token and comes from the page's own origin, or from something that
+// isn't a browser.
+func (guard *FormGuard) Allows(r *http.Request) bool {
+	if subtle.ConstantTimeCompare([]byte(r.FormValue("token")), []byte(guard.token)) != 1 {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host == r.Host
+}
+
+// Returns true if the URL is one of the candidates shown for the game's art
+// style.
+func (server *artworkServer) wasListed(game *Game, artStyle string, pickedURL string) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	for _, candidate := range server.listed[game.ID+"/"+artStyle] {
+		if candidate.URL == pickedURL {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package steamgrid
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+)
+
+// Non-Steam shortcuts are identified differently depending on the client
+// version, and Steam looks for their artwork under the matching ID:
+//
+//   - newer clients store a random appid in shortcuts.vdf, used as an unsigned
+//     32-bit number in grid file names (the same value is also shown as a
+//     signed int in some places, but never in file names);
+//   - older clients compute crc32(target + name) | 0x80000000 and use it
+//     directly as the grid file name;
+//   - some client versions write and read the random appid as a signed int,
+//     e.g. "-1234567890p.png" instead of "3060399406p.png";
+//   - Big Picture mode uses that legacy ID shifted into a 64-bit ID with the
+//     0x02000000 marker, like it does for the appIDs of Steam games.
+
+// Computes the ID older clients use for a shortcut.
+func shortcutLegacyID(target []byte, name []byte) uint32 {
+	return crc32.ChecksumIEEE(bytes.Join([][]byte{target, name}, nil)) | 0x80000000
+}
+
+// Returns the 64-bit ID used by the Big Picture mode for the game's images.
+func legacyGridID(game *Game) (string, bool) {
+	id := game.LegacyID
+	if id == 0 {
+		appID, err := strconv.ParseUint(game.ID, 10, 64)
+		if err != nil {
+			return "", false
+		}
+		id = appID
+	}
+	return strconv.FormatUint(id<<32|0x02000000, 10), true
+}
+
+// Returns the appid of a shortcut as the signed int some clients use in file
+// names, if it's different from the unsigned one.
+func signedGridID(game *Game) (string, bool) {
+	appID, err := strconv.ParseUint(game.ID, 10, 32)
+	if err != nil || appID < 0x80000000 {
+		return "", false
+	}
+	return strconv.FormatInt(int64(int32(uint32(appID))), 10), true
+}
+
+// Returns the IDs, besides game.ID, under which Steam clients may look for
+// the game's artwork.
+func alternateGridIDs(game *Game) []string {
+	var ids []string
+	if game.Custom && game.LegacyID != 0 && fmt.Sprint(game.LegacyID) != game.ID {
+		ids = append(ids, fmt.Sprint(game.LegacyID))
+	}
+	if signedID, ok := signedGridID(game); ok && game.Custom {
+		ids = append(ids, signedID)
+	}
+	if legacyID, ok := legacyGridID(game); ok {
+		ids = append(ids, legacyID)
+	}
+	return ids
+}
@@ -0,0 +1,245 @@
+package steamgrid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Extensions of the files add-shortcuts picks up as launchers. On Linux and
+// macOS, executables and scripts without one are picked up too.
+var launcherExtensions = map[string]bool{
+	".exe":      true,
+	".bat":      true,
+	".cmd":      true,
+	".lnk":      true,
+	".sh":       true,
+	".appimage": true,
+}
+
+// Words in the names of executables that are not the game itself.
+var launcherSkipHints = []string{"unins", "setup", "install", "redist", "crashhandler", "crashreport", "dxsetup", "prereq"}
+
+// A game found by add-shortcuts.
+type launcher struct {
+	Name string
+	Path string
+}
+
+// Starts of executables and scripts: ELF, Mach-O (64 bit and universal) and
+// shebangs.
+var executableMagics = [][]byte{[]byte("\x7fELF"), []byte("\xcf\xfa\xed\xfe"), []byte("\xca\xfe\xba\xbe"), []byte("#!")}
+
+// Returns true if the file starts like an executable or a script. The exec
+// bit alone isn't enough, exFAT and NTFS mounts set it on every file.
+func isExecutable(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	start := make([]byte, 4)
+	n, _ := io.ReadFull(file, start)
+	for _, magic := range executableMagics {
+		if bytes.HasPrefix(start[:n], magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the file in the folder looks like something that starts a
+// game.
+func isLauncher(dir string, info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	name := strings.ToLower(info.Name())
+	for _, hint := range launcherSkipHints {
+		if strings.Contains(name, hint) {
+			return false
+		}
+	}
+	if launcherExtensions[strings.ToLower(filepath.Ext(name))] {
+		return true
+	}
+	return runtime.GOOS != "windows" && info.Mode()&0111 != 0 && isExecutable(filepath.Join(dir, info.Name()))
+}
+
+// Returns the launchers in the folder and in its subfolders. A subfolder
+// with a single launcher is usually the install folder of a game, so the
+// launcher is named after the folder instead of the file.
+func findLaunchers(dir string) ([]launcher, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var launchers []launcher
+	for _, file := range files {
+		path := filepath.Join(dir, file.Name())
+		if !file.IsDir() {
+			if isLauncher(dir, file) {
+				launchers = append(launchers, launcher{strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())), path})
+			}
+			continue
+		}
+		subfiles, err := ioutil.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		var found []launcher
+		for _, subfile := range subfiles {
+			if isLauncher(path, subfile) {
+				found = append(found, launcher{strings.TrimSuffix(subfile.Name(), filepath.Ext(subfile.Name())), filepath.Join(path, subfile.Name())})
+			}
+		}
+		if len(found) == 1 {
+			found[0].Name = file.Name()
+		}
+		launchers = append(launchers, found...)
+	}
+	return launchers, nil
+}
+
+// Returns a new shortcuts.vdf entry for the launcher, with the fields a
+// shortcut added from the Steam client has.
+func newShortcut(index int, l launcher) *vdfNode {
+	exe := `"` + l.Path + `"`
+	startDir := `"` + filepath.Dir(l.Path) + `"`
+	appID := shortcutLegacyID([]byte(exe), []byte(l.Name))
+	return newVdfMap(strconv.Itoa(index),
+		newVdfInt("appid", appID),
+		newVdfString("AppName", l.Name),
+		newVdfString("Exe", exe),
+		newVdfString("StartDir", startDir),
+		newVdfString("icon", ""),
+		newVdfString("ShortcutPath", ""),
+		newVdfString("LaunchOptions", ""),
+		newVdfInt("IsHidden", 0),
+		newVdfInt("AllowDesktopConfig", 1),
+		newVdfInt("AllowOverlay", 1),
+		newVdfInt("OpenVR", 0),
+		newVdfInt("Devkit", 0),
+		newVdfString("DevkitGameID", ""),
+		newVdfInt("DevkitOverrideAppID", 0),
+		newVdfInt("LastPlayTime", 0),
+		newVdfString("FlatpakAppID", ""),
+		newVdfMap("tags"),
+	)
+}
+
+// Adds shortcuts for the launchers missing from the user's shortcuts.vdf,
+// returning how many were added.
+func addShortcuts(user User, launchers []launcher) (int, error) {
+	configDir := findInsensitive(user.Dir, "config")
+	shortcutsVdf := filepath.Join(configDir, "shortcuts.vdf")
+	root := newVdfMap("", newVdfMap("shortcuts"))
+	shortcutBytes, err := ioutil.ReadFile(shortcutsVdf)
+	if err == nil {
+		root, err = parseVdf(shortcutBytes)
+		if err != nil {
+			return 0, err
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+	shortcuts := root.child("shortcuts")
+	if shortcuts == nil || shortcuts.Type != vdfMap {
+		return 0, errors.New("No shortcuts in " + shortcutsVdf)
+	}
+
+	existing := map[string]bool{}
+	for _, shortcut := range shortcuts.Children {
+		if exe := shortcut.child("Exe"); exe != nil {
+			existing[strings.ToLower(strings.Trim(string(exe.Value), `"`))] = true
+		}
+	}
+	added := 0
+	for _, l := range launchers {
+		if existing[strings.ToLower(l.Path)] {
+			continue
+		}
+		shortcuts.Children = append(shortcuts.Children, newShortcut(len(shortcuts.Children), l))
+		existing[strings.ToLower(l.Path)] = true
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	err = os.MkdirAll(configDir, 0777)
+	if err != nil {
+		return 0, err
+	}
+	return added, writeFileAtomic(shortcutsVdf, encodeVdf(root), 0666)
+}
+
+// AddShortcuts adds Steam shortcuts for the games in a folder, then downloads
+// their artwork. Returns the error of the download run.
+func AddShortcuts(ctx context.Context, options Options, dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	launchers, err := findLaunchers(dir)
+	if err != nil {
+		return err
+	}
+	if len(launchers) == 0 {
+		return errors.New("No executables found in " + dir)
+	}
+	fmt.Printf("Found %v games in %v:\n", len(launchers), dir)
+	for _, l := range launchers {
+		fmt.Printf("  %v (%v)\n", l.Name, l.Path)
+	}
+	if options.Confirm && !confirm("Add them to Steam?") {
+		return ErrCancelled
+	}
+
+	installationDir, err := GetSteamInstallation(options.SteamDir)
+	if err != nil {
+		return err
+	}
+	if isSteamRunning() {
+		// Steam writes its own copy of shortcuts.vdf when it exits.
+		if options.CloseSteam {
+			fmt.Println("Closing Steam, it will be reopened when done...")
+			err = closeSteam(ctx, installationDir)
+			if err != nil {
+				return err
+			}
+			defer startSteam(installationDir)
+		} else if options.WaitSteam {
+			fmt.Println("Waiting for Steam to be closed...")
+			err = waitForSteamToClose(ctx, steamWaitTimeout)
+			if err != nil {
+				return err
+			}
+		} else {
+			return errors.New("Steam is running and would undo the new shortcuts when it exits, close it first or use --close-steam")
+		}
+	}
+	users, err := GetUsers(installationDir)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		added, err := addShortcuts(user, launchers)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%v: %v shortcuts added.\n", user.Name, added)
+	}
+
+	options.NonSteamOnly = true
+	options.AppIDs = ""
+	return Run(ctx, options)
+}
@@ -0,0 +1,46 @@
+package steamgrid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFindLaunchersNeedsExecutables(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exec bits are only checked on Linux and macOS")
+	}
+	dir, err := ioutil.TempDir("", "steamgrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		// Everything on exFAT and NTFS mounts looks executable.
+		"readme.txt": "Thanks for playing",
+		"game":       "\x7fELF\x02\x01\x01",
+		"start":      "#!/bin/sh\n./game\n",
+		"setup.sh":   "#!/bin/sh\n",
+		"Game.exe":   "MZ",
+	}
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	launchers, err := findLaunchers(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, l := range launchers {
+		found[filepath.Base(l.Path)] = true
+	}
+	if len(found) != 3 || !found["game"] || !found["start"] || !found["Game.exe"] {
+		t.Errorf("unexpected launchers: %v", launchers)
+	}
+}
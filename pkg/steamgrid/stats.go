@@ -0,0 +1,83 @@
+package steamgrid
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statistics of one image provider for the summary.
+type providerStat struct {
+	searches int
+	found    int
+	// Time spent searching and downloading, found or not.
+	duration time.Duration
+	bytes    int
+}
+
+// Statistics of all providers, shared by the concurrent downloads.
+type providerStats struct {
+	mutex      sync.Mutex
+	byProvider map[string]*providerStat
+}
+
+var statistics = &providerStats{byProvider: map[string]*providerStat{}}
+
+func (s *providerStats) get(provider string) *providerStat {
+	stat, ok := s.byProvider[provider]
+	if !ok {
+		stat = &providerStat{}
+		s.byProvider[provider] = stat
+	}
+	return stat
+}
+
+// Forgets the statistics of a previous run in the same process.
+func (s *providerStats) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.byProvider = map[string]*providerStat{}
+}
+
+// Records a search of the provider and how long it took.
+func (s *providerStats) searched(provider string, duration time.Duration, found bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stat := s.get(provider)
+	stat.searches++
+	stat.duration += duration
+	if found {
+		stat.found++
+	}
+}
+
+// Records the size of an image downloaded from the provider.
+func (s *providerStats) downloaded(provider string, n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.get(provider).bytes += n
+}
+
+// Prints a line per provider with its searches, hit rate, latency and bytes.
+func (s *providerStats) print() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.byProvider) == 0 {
+		return
+	}
+
+	var providers []string
+	for provider := range s.byProvider {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	fmt.Println("Providers:")
+	for _, provider := range providers {
+		stat := s.byProvider[provider]
+		average := stat.duration / time.Duration(stat.searches)
+		fmt.Printf("* %v: %v searches, %v found, %v total, %v average, %.1f MB\n", provider, stat.searches, stat.found, stat.duration.Round(time.Millisecond), average.Round(time.Millisecond), float64(stat.bytes)/1e6)
+	}
+	fmt.Printf("\n")
+}
@@ -0,0 +1,1195 @@
+// Package steamgrid downloads and configures Steam grid images for all games
+// in a Steam installation. The steamgrid command is a thin CLI on top of it.
+//
+// Run does a whole run with the given Options, and the other commands, like
+// Restore, Verify or Rollback, take the same Options and need no other setup.
+// New artwork sources implement ImageProvider and are added with
+// RegisterImageProvider. The Steam discovery, providers, imaging and overlay
+// code share the state of the current run, e.g. the backup folder and the
+// download limits, so they're kept in this one package, and only one command
+// may run at a time.
+package steamgrid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Options for a steamgrid run and the other commands. The command line flags
+// map directly to these, see their help for the accepted values. The zero
+// value of a string field is not always its flag's default, e.g. Run rejects
+// an empty SearchEngine, so start from the CLI defaults when embedding.
+type Options struct {
+	// Api keys and logins of the providers, empty to skip the provider.
+	SteamGridDBApiKey        string
+	IGDBSecret               string
+	IGDBClient               string
+	ScreenScraperDevID       string
+	ScreenScraperDevPassword string
+	ScreenScraperUser        string
+	ScreenScraperPassword    string
+
+	// Steam installation folder, empty to find it.
+	SteamDir string
+
+	// SteamGridDB filters: comma separated styles, logo styles and types
+	// (static, animated), and "false", "true" or "any" for nsfw and humor.
+	SteamGridDBStyles     string
+	SteamGridDBLogoStyles string
+	SteamGridDBTypes      string
+	SteamGridDBNsfw       string
+	SteamGridDBHumor      string
+	// Comma separated WxH dimensions and mime types per art style.
+	SteamGridDBBannerDimensions string
+	SteamGridDBCoverDimensions  string
+	SteamGridDBHeroDimensions   string
+	SteamGridDBLogoDimensions   string
+	SteamGridDBBannerMimes      string
+	SteamGridDBCoverMimes       string
+	SteamGridDBHeroMimes        string
+	SteamGridDBLogoMimes        string
+	// Prefer a SteamGridDB hero and logo by the same author.
+	PairHeroLogo bool
+	// "both" or "small" to prefer heroes for displays under 4K.
+	HeroSize string
+	// Number of SteamGridDB candidates to save for review for ambiguous games.
+	Candidates int
+	// Folder where candidate thumbnails are saved, one subfolder per game.
+	CandidatesDir string
+
+	// JSON files with extra art styles, category renaming rules, and names
+	// or parent appIDs to search for games that are never found.
+	ArtStylesFile  string
+	TagAliasesFile string
+	AppMapFile     string
+
+	// Comma separated categories darkened instead of getting an overlay,
+	// and how much, from 0 to 1.
+	DimCategories string
+	DimStrength   float64
+	// Comma separated category=#RRGGBB:widthpx frames.
+	Borders string
+	// Comma separated category[.style]:effect(amount) image effects.
+	Effects string
+	// Folder with category overlays, named after the category.
+	OverlaysDir string
+	// Only download artwork, or only reapply the overlays to existing images.
+	NoOverlays  bool
+	OverlayOnly bool
+
+	// Fallbacks for games without artwork: a cropped store screenshot, the
+	// artwork of the parent game, and store trailers turned into animations.
+	ScreenshotFallback bool
+	ParentFallback     bool
+	Trailers           bool
+
+	// Skip the Steam servers, the image search and the GOG and Epic stores.
+	SkipSteam  bool
+	SkipGoogle bool
+	SkipStores bool
+	// Image search used as last resort: google, bing or duckduckgo, for
+	// "exact" sizes or "larger" ones.
+	SearchEngine string
+	SearchSize   string
+
+	// Art styles left out of the run.
+	SkipBanner bool
+	SkipCover  bool
+	SkipHero   bool
+	SkipLogo   bool
+
+	// Which games are processed: only non-Steam ones, not the hidden ones,
+	// not the comma separated app types, only the comma separated appIDs.
+	NonSteamOnly bool
+	SkipHidden   bool
+	ExcludeTypes string
+	AppIDs       string
+	// Search with the English name of localized games.
+	EnglishNames bool
+	// Comma separated steps to clean non-Steam game names before searching.
+	CleanNames string
+	// Folder of the RetroArch playlists naming emulator shortcuts, empty for
+	// RetroArch's own folder.
+	RetroArchPlaylists string
+	// Folder with images provided by hand, named after the game ID or name.
+	OverridesDir string
+
+	// Which existing images are replaced: only the ones missing on the
+	// Steam servers, never the ones set by hand, none, the ones from the
+	// comma separated sources, and the comma separated appid=type:id pins.
+	OnlyMissingArtwork bool
+	PreserveCustom     bool
+	NoOverwrite        bool
+	Refresh            string
+	Pins               string
+	// Search again for images not found anywhere in the last 30 days.
+	RetryNotFound bool
+
+	// Save a page with the changes to this file instead of making them.
+	Preview string
+	// Ask before starting, after showing what the run will do.
+	Confirm bool
+	// Close Steam before writing images and reopen it, or wait for it to
+	// be closed.
+	CloseSteam bool
+	WaitSteam  bool
+
+	// Print a line per image instead of a progress bar.
+	Plain bool
+	// Addresses serving Prometheus metrics and Go's pprof profiles during
+	// the run, and the file getting a runtime trace.
+	MetricsAddr string
+	PprofAddr   string
+	TraceFile   string
+	// Webhook getting a summary of the run.
+	WebhookURL string
+
+	// Download rate limit, e.g. "5MB/s", and comma separated name=duration
+	// request timeouts.
+	MaxBandwidth string
+	Timeouts     string
+	// Duration after which the run stops, e.g. "30m".
+	GlobalTimeout string
+	// Memory limit for the images processed at the same time, e.g. "2G".
+	MaxMemory string
+
+	// Check the images and backups, replacing corrupt ones, or the backups
+	// against the manifest.
+	Verify        bool
+	VerifyBackups bool
+	// "off" or "hardlink" to link identical images.
+	Dedup string
+	// Don't write the copies named with the legacy and signed IDs.
+	NoLegacy bool
+	// Gzip the backups, keep them in this folder instead of 'originals', or
+	// keep none.
+	CompressBackups bool
+	BackupDir       string
+	NoBackup        bool
+
+	// Formats written: "apng" or "gif" for animations, the comma separated
+	// formats Steam shows, and comma separated style=format choices.
+	AnimatedFormat string
+	TargetFormats  string
+	OutputFormats  string
+	// JPEG quality from 1 to 100, and "default", "none", "fast" or "best"
+	// PNG compression.
+	JPEGQuality    int
+	PNGCompression string
+}
+
+// Result of a download, reused for the other users in the same run.
+type sharedDownload struct {
+	from       string
+	source     string
+	ext        string
+	imageBytes []byte
+	parentType string
+}
+
+// ErrPartialFailure is returned by Run when some images could not be found or
+// processed.
+var ErrPartialFailure = errors.New("Some images could not be found or processed")
+
+// ErrAuthentication is returned by Run when a provider rejected its api key
+// or login.
+var ErrAuthentication = errors.New("An api key or login was rejected")
+
+// ErrTimeout is returned by Run when it stopped early because of
+// --global-timeout or the deadline of its context, or Steam didn't close in
+// time.
+var ErrTimeout = errors.New("The run took too long and was stopped early")
+
+// ErrCancelled is returned by Run when the user didn't confirm the run.
+var ErrCancelled = errors.New("Cancelled, nothing was changed.")
+
+// Returns the art styles to process, with their SteamGridDB filters built
+// from the options.
+func getArtStyles(options Options) (map[string][]string, error) {
+	for name, value := range map[string]string{"nsfw": options.SteamGridDBNsfw, "humor": options.SteamGridDBHumor} {
+		if value != "false" && value != "true" && value != "any" {
+			return nil, errors.New("--" + name + " must be false, true or any. Got: " + value)
+		}
+	}
+
+	// Build the SteamGridDB filters from the options
+	steamGridDBBannerFilter := "?styles=" + options.SteamGridDBStyles + "&types=" + options.SteamGridDBTypes + "&nsfw=" + options.SteamGridDBNsfw + "&humor=" + options.SteamGridDBHumor + "&dimensions=" + options.SteamGridDBBannerDimensions
+	steamGridDBCoverFilter := "?styles=" + options.SteamGridDBStyles + "&types=" + options.SteamGridDBTypes + "&nsfw=" + options.SteamGridDBNsfw + "&humor=" + options.SteamGridDBHumor + "&dimensions=" + options.SteamGridDBCoverDimensions
+	heroDimensions, heroFallbackFilter := options.SteamGridDBHeroDimensions, ""
+	if options.HeroSize == "small" {
+		// Only the 4K heroes when there are no small ones.
+		small := smallDimensions(heroDimensions, smallHeroWidth)
+		if small != "" && small != heroDimensions {
+			heroFallbackFilter = "?styles=" + options.SteamGridDBStyles + "&types=" + options.SteamGridDBTypes + "&nsfw=" + options.SteamGridDBNsfw + "&humor=" + options.SteamGridDBHumor + "&dimensions=" + heroDimensions
+			heroDimensions = small
+		}
+	} else if options.HeroSize != "both" {
+		return nil, errors.New("--hero-size must be both or small. Got: " + options.HeroSize)
+	}
+	steamGridDBHeroFilter := "?styles=" + options.SteamGridDBStyles + "&types=" + options.SteamGridDBTypes + "&nsfw=" + options.SteamGridDBNsfw + "&humor=" + options.SteamGridDBHumor + "&dimensions=" + heroDimensions
+	steamGridDBLogoFilter := "?styles=" + options.SteamGridDBLogoStyles + "&types=" + options.SteamGridDBTypes + "&nsfw=" + options.SteamGridDBNsfw + "&humor=" + options.SteamGridDBHumor
+	if options.SteamGridDBLogoDimensions != "" {
+		steamGridDBLogoFilter += "&dimensions=" + options.SteamGridDBLogoDimensions
+	}
+
+	for _, mimes := range []string{options.SteamGridDBBannerMimes, options.SteamGridDBCoverMimes, options.SteamGridDBHeroMimes, options.SteamGridDBLogoMimes} {
+		err := validateMimes(mimes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	steamGridDBBannerFilter += mimesFilter(options.SteamGridDBBannerMimes)
+	steamGridDBCoverFilter += mimesFilter(options.SteamGridDBCoverMimes)
+	steamGridDBHeroFilter += mimesFilter(options.SteamGridDBHeroMimes)
+	if heroFallbackFilter != "" {
+		heroFallbackFilter += mimesFilter(options.SteamGridDBHeroMimes)
+	}
+	steamGridDBLogoFilter += mimesFilter(options.SteamGridDBLogoMimes)
+
+	artStyles := map[string][]string{
+		// artStyle: ["idExtension", "nameExtension", steamUrlExtension, steamGridDbFilter, searchDimensions, steamGridDbEndpoint, steamGridDbFallbackFilter]
+		"Banner": []string{"", ".banner", "header.jpg", steamGridDBBannerFilter, firstDimensions(options.SteamGridDBBannerDimensions), "grids"},
+		"Cover":  []string{"p", ".cover", "library_600x900_2x.jpg", steamGridDBCoverFilter, firstDimensions(options.SteamGridDBCoverDimensions), "grids"},
+		"Hero":   []string{"_hero", ".hero", "library_hero.jpg", steamGridDBHeroFilter, firstDimensions(heroDimensions), "heroes", heroFallbackFilter},
+		"Logo":   []string{"_logo", ".logo", "logo.png", steamGridDBLogoFilter, firstDimensions(options.SteamGridDBLogoDimensions), "logos"},
+	}
+	if options.ArtStylesFile != "" {
+		err := addCustomArtStyles(options.ArtStylesFile, options, artStyles)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.SkipBanner {
+		delete(artStyles, "Banner")
+	}
+	if options.SkipCover {
+		delete(artStyles, "Cover")
+	}
+	if options.SkipHero {
+		delete(artStyles, "Hero")
+	}
+	if options.SkipLogo {
+		delete(artStyles, "Logo")
+	}
+	return artStyles, nil
+}
+
+// Clears what a previous Run in the same process, e.g. from the GUI, left in
+// the package state, so its effects and totals aren't applied twice.
+func resetRunState() {
+	categoryEffects = map[string][]imageEffect{}
+	pins = map[string]imagePin{}
+	outputFormats = map[string]string{}
+	tagAliases = compileTagAliases(defaultTagAliases)
+	appMap = map[string]string{}
+	statistics.reset()
+	steamGridDBResults.Lock()
+	steamGridDBResults.byGame = map[steamGridDBResultKey]steamGridDBResult{}
+	steamGridDBResults.Unlock()
+}
+
+// Run downloads and configures the artwork for all games of all users in the
+// Steam installation, printing progress and a report to stdout.
+func Run(ctx context.Context, options Options) error {
+	start := time.Now()
+	resetRunState()
+	err := setupOptions(options)
+	if err != nil {
+		return err
+	}
+	stopDebugging, err := startDebugging(options.PprofAddr, options.TraceFile)
+	if err != nil {
+		return err
+	}
+	defer stopDebugging()
+
+	artStyles, err := getArtStyles(options)
+	if err != nil {
+		return err
+	}
+	if len(artStyles) == 0 {
+		return errors.New("No artStyles, nothing to do…")
+	}
+
+	err = setMaxBandwidth(options.MaxBandwidth)
+	if err != nil {
+		return err
+	}
+
+	err = setTimeouts(options.Timeouts)
+	if err != nil {
+		return err
+	}
+
+	// No new games are started once the context is done, the current ones
+	// finish within their request timeouts.
+	if options.GlobalTimeout != "" {
+		globalTimeout, err := time.ParseDuration(options.GlobalTimeout)
+		if err != nil || globalTimeout <= 0 {
+			return errors.New("Invalid global timeout " + options.GlobalTimeout + ", e.g. 30m")
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, globalTimeout)
+		defer cancel()
+	}
+	timedOut := false
+
+	err = setMaxMemory(options.MaxMemory)
+	if err != nil {
+		return err
+	}
+
+	if options.Pins != "" {
+		err = addPins(options.Pins, artStyles)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.TagAliasesFile != "" {
+		err = loadTagAliases(options.TagAliasesFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.AppMapFile != "" {
+		err = loadAppMap(options.AppMapFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	var excludedTypes map[string]bool
+	if options.ExcludeTypes != "" {
+		excludedTypes, err = parseExcludedTypes(options.ExcludeTypes)
+		if err != nil {
+			return err
+		}
+	}
+
+	dedup, err := newDeduplicator(options.Dedup)
+	if err != nil {
+		return err
+	}
+
+	if options.NoBackup {
+		fmt.Println("Warning: --no-backup is set, the original images won't be kept. They can't be restored with `steamgrid restore`, and changing the overlays means downloading them again.")
+	}
+
+	if _, ok := searchEngines[options.SearchEngine]; !ok {
+		return errors.New("Unknown search engine " + options.SearchEngine + ", must be one of google, bing or duckduckgo")
+	}
+	if options.SearchSize != "exact" && options.SearchSize != "larger" {
+		return errors.New("Unknown search size " + options.SearchSize + ", must be exact or larger")
+	}
+
+	for _, dimensions := range []string{options.SteamGridDBBannerDimensions, options.SteamGridDBCoverDimensions, options.SteamGridDBHeroDimensions, options.SteamGridDBLogoDimensions} {
+		err = validateDimensions(dimensions)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateNameCleaners(options.CleanNames)
+	if err != nil {
+		return err
+	}
+
+	err = setEncoding(options.JPEGQuality, options.PNGCompression)
+	if err != nil {
+		return err
+	}
+
+	err = validateTargetFormats(options.TargetFormats)
+	if err != nil {
+		return err
+	}
+
+	err = setOutputFormats(options.OutputFormats, artStyles)
+	if err != nil {
+		return err
+	}
+	setHeroLogoPairing(options.PairHeroLogo, artStyles)
+
+	if options.AnimatedFormat == "webp" {
+		return errors.New("Animated artwork can't be converted to webp, use apng to keep downloaded WebPs as they are or gif")
+	} else if !isValidAnimatedFormat(options.AnimatedFormat) {
+		return errors.New("Unknown animated format " + options.AnimatedFormat + ", must be one of apng or gif")
+	}
+
+	if options.Refresh != "" && (options.NoOverwrite || options.OverlayOnly) {
+		return errors.New("Can't refresh images without downloading and overwriting them")
+	}
+
+	if options.NoOverwrite && options.OverlayOnly {
+		return errors.New("Can't apply only overlays without overwriting images")
+	}
+
+	if options.Preview != "" && (options.Verify || options.VerifyBackups) {
+		return errors.New("Can't verify images in a preview, it would remove the corrupt ones")
+	}
+
+	if options.NoOverlays && options.OverlayOnly {
+		return errors.New("Can't apply only overlays with overlays turned off")
+	}
+
+	if options.Trailers {
+		if _, err := exec.LookPath("ffmpeg"); err != nil {
+			fmt.Println("Warning: ffmpeg is needed to convert trailers, install it or remove --trailers. Continuing without trailers...")
+			options.Trailers = false
+		}
+	}
+
+	if options.SkipSteam && options.OnlyMissingArtwork {
+		return errors.New("Can't check if official artwork is missing with steam turned off")
+	}
+
+	overlays := map[string]image.Image{}
+	if options.NoOverlays {
+		fmt.Println("Skipping overlays.")
+	} else {
+		fmt.Println("Loading overlays...")
+		var err error
+		overlays, err = LoadOverlays(options.OverlaysDir, artStyles)
+		if err != nil {
+			return err
+		}
+		if options.DimCategories != "" {
+			effect, err := dimEffect(options.DimStrength)
+			if err != nil {
+				return err
+			}
+			addCategoryEffect(options.DimCategories, effect)
+		}
+		if options.Borders != "" {
+			err = addBorderEffects(options.Borders)
+			if err != nil {
+				return err
+			}
+		}
+		if options.Effects != "" {
+			err = addEffects(options.Effects, artStyles)
+			if err != nil {
+				return err
+			}
+		}
+		if len(overlays) == 0 {
+			fmt.Println("No category overlays found. You can put overlay images in the folder 'overlays by category', where the filename is the game category.\n\nYou can find many user-created overlays at https://www.reddit.com/r/steamgrid/wiki/overlays .\n\nContinuing without overlays...")
+		} else {
+			fmt.Printf("Loaded %v overlays. \n\nYou can find many user-created overlays at https://www.reddit.com/r/steamgrid/wiki/overlays .\n\n", len(overlays))
+		}
+	}
+
+	fmt.Println("Looking for Steam directory...\nIf SteamGrid doesn´t find the directory automatically, launch it with an argument linking to the Steam directory.")
+	installationDir, err := GetSteamInstallation(options.SteamDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Loading users...")
+	users, err := GetUsers(installationDir)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return errors.New("No users found at Steam/userdata. Have you used Steam before in this computer?")
+	}
+
+	userGames := map[string]map[string]*Game{}
+	for _, user := range users {
+		fmt.Println("Loading games for " + user.Name)
+		games := GetGames(user, options.NonSteamOnly, options.AppIDs)
+		if options.SkipHidden {
+			removeHiddenGames(games)
+		}
+		if excludedTypes != nil {
+			removeExcludedTypes(games, excludedTypes)
+		}
+		userGames[user.Dir] = games
+	}
+
+	preflight(users, userGames, artStyles, options).print()
+	if options.Confirm && !confirm("Continue?") {
+		return ErrCancelled
+	}
+
+	if isSteamRunning() && options.Preview == "" {
+		if options.CloseSteam {
+			fmt.Println("Closing Steam, it will be reopened when done...")
+			err = closeSteam(ctx, installationDir)
+			if err != nil {
+				return err
+			}
+			defer startSteam(installationDir)
+		} else if options.WaitSteam {
+			fmt.Println("Waiting for Steam to be closed...")
+			err = waitForSteamToClose(ctx, steamWaitTimeout)
+			if err != nil {
+				return err
+			}
+		} else {
+			fmt.Println("Warning: Steam is running. It may not show the new images until it's restarted, use --close-steam to restart it automatically.")
+		}
+	}
+
+	nOverlaysApplied := 0
+	nDownloaded := 0
+	nCandidates := 0
+	nChanged := 0
+	nCorrupt := 0
+	// Images reused from backups or other users' downloads, and images
+	// searched for.
+	nCacheHits := 0
+	nSearches := 0
+	authFailed := false
+	notFounds := map[string][]*Game{
+		"Banner": []*Game{},
+		"Cover":  []*Game{},
+		"Hero":   []*Game{},
+		"Logo":   []*Game{},
+	}
+	steamGridDB := map[string][]*Game{
+		"Banner": []*Game{},
+		"Cover":  []*Game{},
+		"Hero":   []*Game{},
+		"Logo":   []*Game{},
+	}
+	IGDB := map[string][]*Game{
+		"Banner": []*Game{},
+		"Cover":  []*Game{},
+		"Hero":   []*Game{},
+		"Logo":   []*Game{},
+	}
+	screenshotGames := map[string][]*Game{
+		"Banner": []*Game{},
+		"Cover":  []*Game{},
+		"Hero":   []*Game{},
+		"Logo":   []*Game{},
+	}
+	searchedGames := map[string][]*Game{
+		"Banner": []*Game{},
+		"Cover":  []*Game{},
+		"Hero":   []*Game{},
+		"Logo":   []*Game{},
+	}
+	var failures []runFailure
+	progress := NewProgress(options.Plain)
+	if options.MetricsAddr != "" {
+		err := serveMetrics(options.MetricsAddr, progress)
+		if err != nil {
+			progress.Warn("Could not serve the metrics: %v", err.Error())
+		}
+	}
+	// Downloads by game ID and art style, shared between users so each game
+	// is only searched once per run.
+	sharedDownloads := map[string]*sharedDownload{}
+	// Images that weren't found on previous runs, not searched for again
+	// until they expire.
+	notFound := loadNotFoundCache()
+	// AppID -> English name, for localized names, or name of the parent game
+	// in the app map.
+	englishNames := map[string]string{}
+	// Names the run snapshots, see --diff.
+	runTime := time.Now()
+	// Downloaded artwork, to warn about games that got the same images.
+	downloadedArtwork := artworkIndex{}
+	var runPreview *preview
+	if options.Preview != "" {
+		runPreview, err = newPreview(options.Preview)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, user := range users {
+		if timedOut {
+			break
+		}
+		fmt.Println("Processing " + user.Name)
+		gridDir := user.GridDir
+
+		// The Linux version of Steam ships with the "grid" dir without executable bit.
+		// This in turn denies permission to everything inside the folder. This line is
+		// here to ensure we have the correct permission.
+		fmt.Println("Setting permission...")
+		os.Chmod(gridDir, 0777)
+
+		if runPreview == nil {
+			// Makes sure the grid directory exists.
+			err = os.MkdirAll(longPath(gridDir), 0777)
+			if err != nil {
+				fmt.Printf("Skipping %v: %v\n", user.Name, err.Error())
+				failures = append(failures, runFailure{"user " + user.Name, "setup", err})
+				continue
+			}
+		}
+		if runPreview == nil && !options.NoBackup {
+			err = os.MkdirAll(longPath(originalsDir(gridDir)), 0777)
+			if err != nil {
+				fmt.Printf("Skipping %v: %v\n", user.Name, err.Error())
+				failures = append(failures, runFailure{"user " + user.Name, "setup", err})
+				continue
+			}
+			migrateBackups(gridDir)
+		}
+
+		if options.Verify {
+			fmt.Println("Verifying existing images and backups...")
+			removed, err := verifyGridDir(gridDir)
+			if err != nil {
+				fmt.Printf("Skipping %v: %v\n", user.Name, err.Error())
+				failures = append(failures, runFailure{"user " + user.Name, "setup", err})
+				continue
+			}
+			for _, path := range removed {
+				fmt.Printf("Removed corrupt file %v\n", path)
+			}
+			nCorrupt += len(removed)
+		}
+
+		if options.VerifyBackups {
+			fmt.Println("Verifying backups against the manifest...")
+			removed, err := verifyBackups(gridDir, LoadManifest(gridDir))
+			if err != nil {
+				fmt.Printf("Skipping %v: %v\n", user.Name, err.Error())
+				failures = append(failures, runFailure{"user " + user.Name, "setup", err})
+				continue
+			}
+			for _, path := range removed {
+				fmt.Printf("Removed damaged or modified backup %v\n", path)
+			}
+			nCorrupt += len(removed)
+		}
+
+		games := userGames[user.Dir]
+		manifest := LoadManifest(gridDir)
+		previousEntries := map[string]*ManifestEntry{}
+		for key, entry := range manifest.Entries {
+			previousEntries[key] = entry
+		}
+
+		reverted := revertedImages(gridDir, manifest)
+		if len(reverted) > 0 {
+			sort.Strings(reverted)
+			fmt.Printf("Warning: %v images were changed since the last run, possibly reverted by Steam Cloud:\n", len(reverted))
+			for _, path := range reverted {
+				fmt.Printf("* %v\n", path)
+			}
+		}
+		if runPreview == nil {
+			waitForCloudSync(user)
+		}
+
+		fmt.Println("Loading existing images and backups...")
+		progress.AddGames(len(games))
+
+		for _, game := range games {
+			if ctx.Err() != nil {
+				timedOut = true
+				break
+			}
+			var name string
+			if game.Name == "" && !options.OverlayOnly {
+				game.Name = getGameName(game.ID)
+			} else if game.Name == "" && !game.Custom {
+				// Nothing is downloaded in overlay-only mode, but the cached
+				// app list can still name the game.
+				game.Name = appListName(game.ID, false)
+			}
+
+			if game.Name != "" {
+				name = game.Name
+			} else {
+				name = "unknown game with id " + game.ID
+			}
+			gameProgress := progress.StartGame(name)
+
+			styleGames := map[string]*Game{}
+			entries := map[string]*ManifestEntry{}
+			// Clean images already in the grid, to recognize downloads of the
+			// same artwork.
+			installed := map[string][]byte{}
+			for artStyle, artStyleExtensions := range artStyles {
+				if skipsArtStyle(game, artStyle) {
+					gameProgress.Info("%v skipped by category", artStyle)
+					continue
+				}
+				if options.NoOverwrite && hasGridImage(gridDir, game.ID, artStyleExtensions) {
+					gameProgress.Info("%v already exists, leaving it untouched", artStyle)
+					continue
+				}
+
+				// Each art style works on its own copy of the game, so their
+				// images can be downloaded in parallel.
+				styleGame := *game
+				game := &styleGame
+
+				loadExisting(options.OverridesDir, gridDir, game, artStyleExtensions)
+				entry := manifest.Get(game.ID, artStyleExtensions)
+				if options.Verify && game.ImageSource == "" && entry != nil {
+					// The image was removed as corrupt, but the backup of what
+					// we wrote may still be there.
+					loadManifestBackup(gridDir, game, artStyleExtensions, entry)
+				}
+				if game.ImageSource == "backup" {
+					installed[artStyle] = game.CleanImageBytes
+				}
+				if entry != nil && (game.ImageSource == "backup" || options.NoBackup) {
+					setParentType(game, entry.ParentType)
+				}
+				if pin, ok := getPin(game, artStyle); ok && !options.OverlayOnly && (entry == nil || entry.Source != pin.source()) {
+					gameProgress.Info("Using pinned %v %v", artStyle, pin.spec)
+					game.ImageSource = ""
+					game.ImageExt = ""
+					game.CleanImageBytes = nil
+				} else if shouldRefresh(options.Refresh, game, entry) {
+					gameProgress.Info("Refreshing %v from %v", artStyle, game.ImageSource)
+					game.ImageSource = ""
+					game.ImageExt = ""
+					game.CleanImageBytes = nil
+				} else if options.NoBackup && game.ImageSource == manualCustomizationSource && entry != nil && entry.Hash == imageHash(game.CleanImageBytes) && sameTags(entry.OverlayTags, matchingOverlayTags(game, overlays, artStyleExtensions)) {
+					// Our own image without a backup, which is fine as long as
+					// the overlays stay the same.
+					gameProgress.Info("%v is up to date", artStyle)
+					continue
+				} else if game.ImageSource == manualCustomizationSource && entry != nil && entry.Hash == imageHash(game.CleanImageBytes) {
+					// Our own image with overlays, but the clean backup is gone.
+					// Download it again instead of stacking more overlays on top.
+					game.ImageSource = ""
+					game.ImageExt = ""
+					game.CleanImageBytes = nil
+				} else if options.PreserveCustom && game.ImageSource == manualCustomizationSource {
+					gameProgress.Info("%v was set by hand, leaving it untouched", artStyle)
+					continue
+				} else if entry != nil && game.ImageSource == "backup" && !sameTags(entry.OverlayTags, matchingOverlayTags(game, overlays, artStyleExtensions)) {
+					gameProgress.Info("Categories changed, reapplying %v overlays", artStyle)
+				}
+				if game.ImageSource == "" && options.OverlayOnly {
+					// Nothing to apply overlays to.
+					continue
+				}
+				styleGames[artStyle] = game
+				entries[artStyle] = entry
+			}
+
+			///////////////////////
+			// Download if missing.
+			//
+			// All art styles at once, they hit different URLs.
+			///////////////////////
+			froms := map[string]string{}
+			downloadErrors := map[string]error{}
+			candidates := map[string]steamGridDBResult{}
+			reused := map[string]bool{}
+			skipped := map[string]bool{}
+			var wg sync.WaitGroup
+			var mutex sync.Mutex
+			// Name sent to external providers, looked up before the first download.
+			searchedName := ""
+			providers := providerNames(options)
+			for artStyle, styleGame := range styleGames {
+				if styleGame.ImageSource == "backup" {
+					nCacheHits++
+				}
+				if styleGame.ImageSource != "" {
+					continue
+				}
+				if cached, ok := sharedDownloads[game.ID+artStyles[artStyle][0]]; ok {
+					// Already searched for another user.
+					nCacheHits++
+					styleGame.ImageSource = cached.source
+					styleGame.ImageExt = cached.ext
+					styleGame.CleanImageBytes = cached.imageBytes
+					setParentType(styleGame, cached.parentType)
+					froms[artStyle] = cached.from
+					reused[artStyle] = true
+					continue
+				}
+				if searchedName == "" {
+					searchedName = searchName(game, options, englishNames)
+				}
+				if _, pinned := getPin(styleGame, artStyle); !pinned && !options.RetryNotFound {
+					if until, ok := notFound.until(game.ID+artStyles[artStyle][0], searchedName, providers); ok {
+						gameProgress.Info("%v was not found on a previous run, not searching again until %v", artStyle, until.Format("2006-01-02"))
+						froms[artStyle] = ""
+						skipped[artStyle] = true
+						continue
+					}
+				}
+				nSearches++
+				wg.Add(1)
+				go func(artStyle string, styleGame *Game) {
+					defer wg.Done()
+					name, id, custom := styleGame.Name, styleGame.ID, styleGame.Custom
+					if searchedName != "" {
+						styleGame.Name = searchedName
+					}
+					if parentID, ok := mappedParent(styleGame); ok {
+						// Pins still belong to the game itself.
+						if _, pinned := getPin(styleGame, artStyle); !pinned {
+							styleGame.ID = parentID
+							styleGame.Custom = false
+						}
+					}
+					from, err := DownloadImage(gridDir, styleGame, artStyle, artStyles[artStyle], options)
+					if err == nil && options.Trailers {
+						var trailerErr error
+						from, trailerErr = useTrailer(styleGame, artStyle, artStyles[artStyle], from, options)
+						if trailerErr != nil {
+							gameProgress.Warn("Keeping the static %v: %v", artStyle, trailerErr.Error())
+						}
+					}
+					result := steamGridDBCandidates(styleGame, artStyles[artStyle], from)
+					styleGame.Name, styleGame.ID, styleGame.Custom = name, id, custom
+					mutex.Lock()
+					froms[artStyle] = from
+					downloadErrors[artStyle] = err
+					candidates[artStyle] = result
+					mutex.Unlock()
+				}(artStyle, styleGame)
+			}
+			wg.Wait()
+
+			if len(users) > 1 {
+				for artStyle, from := range froms {
+					if downloadErrors[artStyle] == nil && !reused[artStyle] {
+						styleGame := styleGames[artStyle]
+						sharedDownloads[game.ID+artStyles[artStyle][0]] = &sharedDownload{from, styleGame.ImageSource, styleGame.ImageExt, styleGame.CleanImageBytes, styleGame.ParentType}
+					}
+				}
+			}
+
+			// Memory of the image being written, held from the overlay to the
+			// write as the conversions and encoding need it too. Released at the
+			// start of the next art style, so every continue gives it back.
+			releaseImage := func() {}
+			for artStyle, styleGame := range styleGames {
+				releaseImage()
+				artStyleExtensions := artStyles[artStyle]
+				entry := entries[artStyle]
+
+				if from, downloaded := froms[artStyle]; downloaded {
+					err := downloadErrors[artStyle]
+					if clearFailedAuth(&options, err) {
+						authFailed = true
+					}
+					if err != nil {
+						gameProgress.Warn("%v", err.Error())
+					}
+
+					if styleGame.ImageSource == "" && err == nil && !skipped[artStyle] && !reused[artStyle] {
+						notFound.record(game.ID+artStyleExtensions[0], searchedName, providers)
+					} else if styleGame.ImageSource != "" {
+						notFound.forget(game.ID + artStyleExtensions[0])
+					}
+
+					if styleGame.ImageSource == "" {
+						notFounds[artStyle] = append(notFounds[artStyle], game)
+						gameProgress.NotFound(artStyle)
+						// Game has no image, skip it.
+						continue
+					} else if err == nil && !reused[artStyle] {
+						nDownloaded++
+						gameProgress.Downloaded(len(styleGame.CleanImageBytes))
+					}
+
+					switch from {
+					case "IGDB":
+						IGDB[artStyle] = append(IGDB[artStyle], game)
+					case "SteamGridDB":
+						steamGridDB[artStyle] = append(steamGridDB[artStyle], game)
+						// Reused images had their candidates saved for the
+						// first user.
+						if options.Candidates > 0 && !reused[artStyle] {
+							saved, err := saveSteamGridDBCandidates(httpClient, options.CandidatesDir, styleGame, artStyle, candidates[artStyle])
+							if err != nil {
+								gameProgress.Warn("%v", err.Error())
+							} else if saved > 0 {
+								gameProgress.Info("Ambiguous match, saved %v %v candidates for review", saved, artStyle)
+								nCandidates++
+							}
+						}
+					case "search":
+						searchedGames[artStyle] = append(searchedGames[artStyle], game)
+					case screenshotSource:
+						screenshotGames[artStyle] = append(screenshotGames[artStyle], game)
+					}
+				}
+				gameProgress.Found(artStyle, styleGame.ImageSource)
+				if _, downloaded := froms[artStyle]; downloaded {
+					downloadedArtwork.add(artStyle, styleGame, styleGame.CleanImageBytes)
+				}
+
+				if previous, ok := installed[artStyle]; ok && entry != nil && styleGame.ImageSource != "backup" && sameTags(entry.OverlayTags, matchingOverlayTags(styleGame, overlays, artStyleExtensions)) && isSameImage(previous, styleGame.CleanImageBytes) {
+					// Nothing would change, skip the backup and write.
+					gameProgress.Info("The new %v is the same artwork as the installed one, keeping it", artStyle)
+					entry.Source = styleGame.ImageSource
+					continue
+				}
+
+				///////////////////////
+				// Apply overlay.
+				//
+				// Expecting name.artExt.imgExt:
+				// Banner: favorites.png
+				// Cover: favorites.p.png
+				// Hero: favorites.hero.png
+				// Logo: favorites.logo.png
+				///////////////////////
+				releaseImage = reserveImageMemory(styleGame.CleanImageBytes)
+				if artStyle == "Logo" {
+					err := convertLogo(styleGame)
+					if err != nil {
+						gameProgress.Warn("Failed to convert logo of %v to png: %v", styleGame.Name, err.Error())
+					}
+				}
+				err := applyOverlay(styleGame, overlays, artStyleExtensions)
+				if err != nil {
+					gameProgress.Warn("%v", err.Error())
+					failures = append(failures, runFailure{imageSubject(game, artStyle), "overlay", err})
+				}
+				if styleGame.OverlayImageBytes != nil {
+					nOverlaysApplied++
+				} else {
+					styleGame.OverlayImageBytes = styleGame.CleanImageBytes
+				}
+
+				imageExt, err := convertAnimated(styleGame, artStyle, options.AnimatedFormat)
+				if err != nil {
+					gameProgress.Warn("Failed to convert animated %v for %v: %v", artStyle, styleGame.Name, err.Error())
+				}
+				imageExt, err = applyOutputFormat(styleGame, artStyle, imageExt)
+				if err != nil {
+					gameProgress.Warn("Failed to convert %v for %v: %v", artStyle, styleGame.Name, err.Error())
+				}
+
+				///////////////////////
+				// Save result.
+				///////////////////////
+				if runPreview != nil {
+					err = runPreview.add(user, styleGame, artStyle, artStyleExtensions, styleGame.OverlayImageBytes, imageExt)
+					if err != nil {
+						gameProgress.Warn("Failed to add %v of %v to the preview: %v", artStyle, styleGame.Name, err.Error())
+					}
+					continue
+				}
+				// This cleans up unused backups and images for the same game but with different extensions.
+				err = removeExisting(gridDir, styleGame.ID, artStyleExtensions, options.NoBackup)
+				if err != nil {
+					gameProgress.Warn("%v", err.Error())
+				}
+				backupPath := ""
+				err = nil
+				if !options.NoBackup {
+					backupPath, err = backupGame(gridDir, styleGame, artStyleExtensions, options.CompressBackups)
+				}
+				if err != nil {
+					// Without a backup the clean image would be lost, so the
+					// image is left as it is.
+					gameProgress.Warn("Failed to back up %v for %v because: %v", artStyle, styleGame.Name, err.Error())
+					failures = append(failures, runFailure{imageSubject(game, artStyle), "backup", err})
+					continue
+				}
+				backupName := ""
+				if backupPath != "" {
+					backupName = filepath.Base(backupPath)
+				}
+
+				imagePath := filepath.Join(gridDir, styleGame.ID+artStyleExtensions[0]+imageExt)
+				err = dedup.write(imagePath, styleGame.OverlayImageBytes)
+				if err == nil {
+					source := styleGame.ImageSource
+					if source == "backup" && entry != nil {
+						// Keep track of where the image originally came from.
+						source = entry.Source
+					}
+					cleanHash := ""
+					if !options.NoBackup {
+						cleanHash = imageHash(styleGame.CleanImageBytes)
+					}
+					var copies []string
+					if !options.NoLegacy {
+						copies = copyKeys(styleGame, artStyleExtensions)
+					}
+					manifest.Set(styleGame.ID, artStyleExtensions, &ManifestEntry{
+						Source:      source,
+						ImageExt:    imageExt,
+						Hash:        imageHash(styleGame.OverlayImageBytes),
+						CleanHash:   cleanHash,
+						Backup:      backupName,
+						OverlayTags: matchingOverlayTags(styleGame, overlays, artStyleExtensions),
+						ParentType:  styleGame.ParentType,
+						Copies:      copies,
+						Time:        time.Now(),
+					})
+				}
+
+				// Copies for older clients and Big Picture mode. The old copies
+				// are always removed, so --no-legacy cleans them up.
+				for _, id := range alternateGridIDs(styleGame) {
+					if err == nil {
+						err = removeExisting(gridDir, id, artStyleExtensions, options.NoBackup)
+					}
+					if err == nil && !options.NoLegacy {
+						err = dedup.write(filepath.Join(gridDir, id+artStyleExtensions[0]+imageExt), styleGame.OverlayImageBytes)
+					}
+				}
+				if err != nil {
+					gameProgress.Warn("Failed to write image for %v (%v) because: %v", styleGame.Name, artStyle, err.Error())
+					failures = append(failures, runFailure{imageSubject(game, artStyle), "write", err})
+				}
+			}
+			releaseImage()
+			gameProgress.FinishGame()
+
+			if runPreview != nil {
+				continue
+			}
+			// Saved after every game so an interrupted run keeps its progress.
+			err = manifest.Save()
+			if err != nil {
+				progress.Warn("Failed to save manifest for %v because: %v", user.Name, err.Error())
+			}
+		}
+
+		if runPreview != nil {
+			continue
+		}
+		nChanged += manifest.countChanged(previousEntries)
+		err = manifest.SaveSnapshot(runTime, !options.NoBackup)
+		if err != nil {
+			progress.Warn("Failed to save the run snapshot for %v because: %v", user.Name, err.Error())
+		}
+	}
+
+	err = notFound.save()
+	if err != nil {
+		progress.Warn("Failed to save the images that weren't found: %v", err.Error())
+	}
+
+	progress.Done()
+	if runPreview != nil {
+		err = runPreview.write()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n\nNothing was changed. %v images would change, see %v.\n", len(runPreview.Items), options.Preview)
+		return nil
+	}
+	fmt.Printf("\n\n%v images downloaded and %v overlays applied, %v images changed since the last run.\n\n", nDownloaded, nOverlaysApplied, nChanged)
+	statistics.print()
+	if nCacheHits+nSearches > 0 {
+		fmt.Printf("%v of %v images (%.0f%%) came from backups or were reused across users without searching.\n\n", nCacheHits, nCacheHits+nSearches, 100*float64(nCacheHits)/float64(nCacheHits+nSearches))
+	}
+	if dedup.saved > 0 {
+		fmt.Printf("%.1f MB saved by linking identical images.\n\n", float64(dedup.saved)/1e6)
+	}
+	if nCorrupt > 0 {
+		fmt.Printf("%v corrupt files were removed, their images were restored from backups or downloaded again.\n\n", nCorrupt)
+	}
+	if nCandidates > 0 {
+		fmt.Printf("%v images had ambiguous matches on SteamGridDB. Review the candidates in %v and copy the right ones to the 'games' folder.\n\n", nCandidates, options.CandidatesDir)
+	}
+	if countGames(searchedGames) >= 1 {
+		fmt.Printf("%v images were found with an image search and may not be accurate:\n", countGames(searchedGames))
+		for artStyle, games := range searchedGames {
+			for _, game := range games {
+				fmt.Printf("* %v (steam id %v, %v)\n", game.Name, game.ID, artStyle)
+			}
+		}
+
+		fmt.Printf("\n\n")
+	}
+
+	if countGames(screenshotGames) >= 1 {
+		fmt.Printf("%v images were made from store screenshots because no artwork was found, and are low confidence:\n", countGames(screenshotGames))
+		for artStyle, games := range screenshotGames {
+			for _, game := range games {
+				fmt.Printf("* %v (steam id %v, %v)\n", game.Name, game.ID, artStyle)
+			}
+		}
+
+		fmt.Printf("\n\n")
+	}
+
+	if countGames(IGDB) >= 1 {
+		fmt.Printf("%v images were found on IGDB and may not be in full quality or accurate:\n", countGames(IGDB))
+		for artStyle, games := range IGDB {
+			for _, game := range games {
+				fmt.Printf("* %v (steam id %v, %v)\n", game.Name, game.ID, artStyle)
+			}
+		}
+
+		fmt.Printf("\n\n")
+	}
+
+	if countGames(steamGridDB) >= 1 {
+		fmt.Printf("%v images were found on SteamGridDB and may not be in full quality or accurate:\n", countGames(steamGridDB))
+		for artStyle, games := range steamGridDB {
+			for _, game := range games {
+				fmt.Printf("* %v (steam id %v, %v)\n", game.Name, game.ID, artStyle)
+			}
+		}
+
+		fmt.Printf("\n\n")
+	}
+
+	downloadedArtwork.printDuplicates()
+
+	if countGames(notFounds) >= 1 {
+		fmt.Printf("%v images could not be found anywhere:\n", countGames(notFounds))
+		for artStyle, games := range notFounds {
+			for _, game := range games {
+				fmt.Printf("- %v (id %v, %v)\n", game.Name, game.ID, artStyle)
+			}
+		}
+
+		fmt.Printf("\n\n")
+	}
+
+	printFailures(failures)
+
+	var result error
+	status := "success"
+	if timedOut && ctx.Err() == context.Canceled {
+		fmt.Printf("Stopped early, the remaining games will be processed on the next run.\n\n")
+		result = ctx.Err()
+		status = "cancelled"
+	} else if timedOut {
+		fmt.Printf("Stopped after the global timeout of %v, the remaining games will be processed on the next run.\n\n", options.GlobalTimeout)
+		result = ErrTimeout
+		status = "timeout"
+	} else if authFailed {
+		result = ErrAuthentication
+		status = "authentication failed"
+	} else if countGames(notFounds)+len(failures) > 0 {
+		result = ErrPartialFailure
+		status = "partial"
+	}
+
+	if options.WebhookURL != "" {
+		err = postWebhook(options.WebhookURL, runSummary{
+			Downloaded:      nDownloaded,
+			OverlaysApplied: nOverlaysApplied,
+			Changed:         nChanged,
+			NotFound:        countGames(notFounds),
+			Failed:          len(failures),
+			Status:          status,
+			Seconds:         time.Since(start).Seconds(),
+		})
+		if err != nil {
+			fmt.Printf("Failed to post the summary to the webhook: %v\n", err.Error())
+		}
+	}
+	return result
+}
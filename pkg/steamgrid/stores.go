@@ -0,0 +1,173 @@
+package steamgrid
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Picks the index of the title matching the game name, ignoring case, or -1
+// if none does. The stores' first result is often a different game entirely.
+func bestTitleMatch(gameName string, titles []string) int {
+	for i, title := range titles {
+		if strings.EqualFold(strings.TrimSpace(title), strings.TrimSpace(gameName)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// https://catalog.gog.com/v1/catalog
+const gogCatalogURL = "https://catalog.gog.com/v1/catalog?limit=5&productType=in:game&query=like:"
+
+type gogCatalogResponse struct {
+	Products []struct {
+		Title           string
+		CoverHorizontal string
+		CoverVertical   string
+	}
+}
+
+// Store artwork from GOG, only used for non-Steam shortcuts.
+type gogProvider struct {
+	client *http.Client
+}
+
+func (gogProvider) Name() string { return "GOG" }
+
+func (p gogProvider) Search(game *Game, artStyle string, artStyleExtensions []string) ([]string, error) {
+	if !game.Custom || game.Name == "" || (artStyle != "Cover" && artStyle != "Hero") {
+		return nil, nil
+	}
+
+	response, err := tryDownload(p.client, gogCatalogURL+url.QueryEscape(game.Name))
+	if err != nil || response == nil {
+		return nil, err
+	}
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var jsonResponse gogCatalogResponse
+	err = json.Unmarshal(responseBytes, &jsonResponse)
+	if err != nil || len(jsonResponse.Products) == 0 {
+		return nil, nil
+	}
+
+	var titles []string
+	for _, product := range jsonResponse.Products {
+		titles = append(titles, product.Title)
+	}
+	match := bestTitleMatch(game.Name, titles)
+	if match < 0 {
+		return nil, nil
+	}
+	product := jsonResponse.Products[match]
+
+	imageURL := product.CoverVertical
+	if artStyle == "Hero" {
+		imageURL = product.CoverHorizontal
+	}
+	if imageURL == "" {
+		return nil, nil
+	}
+	return []string{imageURL}, nil
+}
+
+const epicGraphQLURL = "https://store.epicgames.com/graphql"
+const epicSearchQuery = `query searchStoreQuery($keywords: String, $country: String!) {
+	Catalog {
+		searchStore(keywords: $keywords, country: $country, count: 5, category: "games/edition/base") {
+			elements { title keyImages { type url } }
+		}
+	}
+}`
+
+type epicSearchResponse struct {
+	Data struct {
+		Catalog struct {
+			SearchStore struct {
+				Elements []struct {
+					Title     string
+					KeyImages []struct {
+						Type string
+						URL  string
+					}
+				}
+			}
+		}
+	}
+}
+
+// Epic key image types for each art style.
+var epicImageTypes = map[string]string{
+	"Cover": "DieselStoreFrontTall",
+	"Hero":  "DieselStoreFrontWide",
+}
+
+// Store artwork from the Epic Games Store, only used for non-Steam shortcuts.
+type epicProvider struct {
+	client *http.Client
+}
+
+func (epicProvider) Name() string { return "Epic Games Store" }
+
+func (p epicProvider) Search(game *Game, artStyle string, artStyleExtensions []string) ([]string, error) {
+	imageType, ok := epicImageTypes[artStyle]
+	if !game.Custom || game.Name == "" || !ok {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"query": epicSearchQuery,
+		"variables": map[string]string{
+			"keywords": game.Name,
+			"country":  "US",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := p.client.Post(epicGraphQLURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 400 {
+		return nil, nil
+	}
+
+	var jsonResponse epicSearchResponse
+	err = json.Unmarshal(responseBytes, &jsonResponse)
+	elements := jsonResponse.Data.Catalog.SearchStore.Elements
+	if err != nil || len(elements) == 0 {
+		return nil, nil
+	}
+
+	var titles []string
+	for _, element := range elements {
+		titles = append(titles, element.Title)
+	}
+	match := bestTitleMatch(game.Name, titles)
+	if match < 0 {
+		return nil, nil
+	}
+	element := elements[match]
+
+	for _, keyImage := range element.KeyImages {
+		if keyImage.Type == imageType && keyImage.URL != "" {
+			return []string{keyImage.URL}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,67 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// Rule renaming matching categories before looking up their overlay. Pattern
+// is a case-insensitive regex matched against the whole lower-cased
+// category, and Name may use its groups, e.g. "$1".
+type tagAlias struct {
+	Pattern string
+	Name    string
+	regex   *regexp.Regexp
+}
+
+// Aliases used when no file is given: English and German spellings of
+// "favorite", then removing the trailing "s" of plurals.
+var defaultTagAliases = []tagAlias{
+	{Pattern: `favou?rites?|favoriten`, Name: "favorite"},
+	{Pattern: `(.*?)s*`, Name: "$1"},
+}
+
+// Aliases applied by normalizeTag, in order. The first matching one wins.
+var tagAliases = compileTagAliases(defaultTagAliases)
+
+func compileTagAliases(aliases []tagAlias) []tagAlias {
+	for i := range aliases {
+		aliases[i].regex = regexp.MustCompile(`(?i)^(?:` + aliases[i].Pattern + `)$`)
+	}
+	return aliases
+}
+
+// Replaces the default tag aliases with the ones in a JSON file, a list of
+// {"pattern": ..., "name": ...}.
+func loadTagAliases(path string) error {
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var aliases []tagAlias
+	err = json.Unmarshal(fileBytes, &aliases)
+	if err != nil {
+		return errors.New("Invalid tag aliases file " + path + ": " + err.Error())
+	}
+	for _, alias := range aliases {
+		if _, err := regexp.Compile(alias.Pattern); err != nil {
+			return errors.New("Invalid tag alias pattern " + alias.Pattern + ": " + err.Error())
+		}
+	}
+	tagAliases = compileTagAliases(aliases)
+	return nil
+}
+
+// Returns the category name after the first matching alias, lower-cased.
+func aliasTag(tag string) string {
+	tag = strings.ToLower(tag)
+	for _, alias := range tagAliases {
+		if alias.regex.MatchString(tag) {
+			return strings.ToLower(alias.regex.ReplaceAllString(tag, alias.Name))
+		}
+	}
+	return tag
+}
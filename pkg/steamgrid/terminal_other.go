@@ -0,0 +1,32 @@
+//go:build !windows
+
+package steamgrid
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Makes the terminal pass on each key as soon as it's pressed, without
+// echoing it. Returns the function putting the terminal back as it was.
+func rawTerminal() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	_, err = stty("-icanon", "-echo", "-isig", "min", "1")
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		stty(strings.TrimSpace(saved))
+	}, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+	return string(output), err
+}
@@ -0,0 +1,41 @@
+package steamgrid
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Console modes, see SetConsoleMode.
+const (
+	enableProcessedInput       = 0x1
+	enableLineInput            = 0x2
+	enableEchoInput            = 0x4
+	enableVirtualTerminalInput = 0x200
+)
+
+var (
+	kernel32           = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleMode = kernel32.NewProc("GetConsoleMode")
+	procSetConsoleMode = kernel32.NewProc("SetConsoleMode")
+)
+
+// Makes the console pass on each key as soon as it's pressed, without
+// echoing it, and the arrow keys as escape sequences like other terminals.
+// Returns the function putting the console back as it was.
+func rawTerminal() (func(), error) {
+	handle := os.Stdin.Fd()
+	var saved uint32
+	ok, _, err := procGetConsoleMode.Call(handle, uintptr(unsafe.Pointer(&saved)))
+	if ok == 0 {
+		return nil, err
+	}
+	mode := saved&^(enableProcessedInput|enableLineInput|enableEchoInput) | enableVirtualTerminalInput
+	ok, _, err = procSetConsoleMode.Call(handle, uintptr(mode))
+	if ok == 0 {
+		return nil, err
+	}
+	return func() {
+		procSetConsoleMode.Call(handle, uintptr(saved))
+	}, nil
+}
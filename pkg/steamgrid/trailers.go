@@ -0,0 +1,123 @@
+package steamgrid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Source of animated images made from store trailers.
+const trailerSource = "Steam trailer"
+
+// Official store API, with only the trailers of the app.
+const steamMoviesURL = "https://store.steampowered.com/api/appdetails?filters=movies&appids="
+
+// Short muted clip Steam plays when hovering a game in the store.
+const microtrailerURLFormat = "https://cdn.akamai.steamstatic.com/steam/apps/%v/microtrailer.webm"
+
+// Length in seconds and frame rate of the loop cut from the trailer.
+const trailerLoopSeconds = 3
+const trailerLoopFPS = 12
+
+type steamMoviesResponse map[string]struct {
+	Success bool
+	Data    struct {
+		Movies []struct {
+			ID int
+		}
+	}
+}
+
+// Replaces the static banner or hero just downloaded for the game with a loop
+// of its store trailer, for --trailers. Pinned images and the official Steam
+// art are kept, and so is the art found on SteamGridDB unless it was searched
+// for animated art too, as it would have been preferred. Returns the source
+// of the image, which is unchanged if no trailer was used. On errors the
+// static image is kept.
+func useTrailer(game *Game, artStyle string, artStyleExtensions []string, from string, options Options) (string, error) {
+	if game.Custom || (artStyle != "Banner" && artStyle != "Hero") || isAnimated(game.CleanImageBytes) {
+		return from, nil
+	}
+	if _, pinned := getPin(game, artStyle); pinned || from == "" || from == "steam server" {
+		return from, nil
+	}
+	if from == "SteamGridDB" && !strings.Contains(options.SteamGridDBTypes, "animated") {
+		return from, nil
+	}
+
+	trailerBytes, err := getTrailerImage(httpClient, game, artStyleExtensions)
+	if err != nil || trailerBytes == nil {
+		return from, err
+	}
+	statistics.downloaded(trailerSource, len(trailerBytes))
+	game.CleanImageBytes = trailerBytes
+	game.ImageExt = ".png"
+	game.ImageSource = trailerSource
+	return trailerSource, nil
+}
+
+// Downloads the microtrailer of a Steam game and converts its first seconds
+// to an APNG loop with the size of the art style. Needs ffmpeg in the PATH.
+// Returns nil if the game has no trailers.
+func getTrailerImage(client *http.Client, game *Game, artStyleExtensions []string) ([]byte, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, errors.New("ffmpeg is needed to convert trailers, install it or remove --trailers")
+	}
+
+	response, err := tryDownload(client, steamMoviesURL+game.ID)
+	if err != nil || response == nil {
+		return nil, err
+	}
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var jsonResponse steamMoviesResponse
+	err = json.Unmarshal(responseBytes, &jsonResponse)
+	if err != nil {
+		return nil, err
+	}
+	details, ok := jsonResponse[game.ID]
+	if !ok || !details.Success || len(details.Data.Movies) == 0 {
+		return nil, nil
+	}
+
+	response, err = tryDownload(client, fmt.Sprintf(microtrailerURLFormat, details.Data.Movies[0].ID))
+	if err != nil || response == nil {
+		return nil, err
+	}
+	videoBytes, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	tempDir, err := ioutil.TempDir("", "steamgrid")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+	videoPath := filepath.Join(tempDir, "trailer.webm")
+	loopPath := filepath.Join(tempDir, "loop.png")
+	err = ioutil.WriteFile(videoPath, videoBytes, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	// Crop the center to the aspect ratio of the art style, then scale.
+	width, height := searchDimensions(artStyleExtensions)
+	filter := fmt.Sprintf("fps=%v,crop='min(iw,ih*%v/%v)':'min(ih,iw*%v/%v)',scale=%v:%v", trailerLoopFPS, width, height, height, width, width, height)
+	output, err := exec.Command(ffmpeg, "-loglevel", "error", "-y", "-t", fmt.Sprint(trailerLoopSeconds), "-i", videoPath, "-an", "-vf", filter, "-plays", "0", "-f", "apng", loopPath).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg failed to convert the trailer of %v: %v %s", game.Name, err, output)
+	}
+	return ioutil.ReadFile(loopPath)
+}
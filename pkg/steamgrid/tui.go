@@ -0,0 +1,271 @@
+package steamgrid
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Games listed per page in the terminal interface.
+const tuiPageSize = 20
+
+// Clears the terminal and moves the cursor to the top.
+const tuiClearScreen = "\033[H\033[2J"
+
+// Names of the keys that aren't characters, as returned by readKey.
+const (
+	keyUp    = "up"
+	keyDown  = "down"
+	keyLeft  = "left"
+	keyRight = "right"
+	keyEnter = "enter"
+	keyEsc   = "esc"
+)
+
+// Terminal interface to browse the games and pick artwork by hand.
+type artworkTUI struct {
+	*artworkBrowser
+	input *bufio.Reader
+	// Whether keys are read as they're pressed. Otherwise, e.g. when the
+	// input isn't a terminal, they're read a line at a time.
+	raw   bool
+	games []*Game
+	// Last search results for the open game, art style -> candidates.
+	candidates map[string][]artworkCandidate
+	message    string
+}
+
+// A candidate of the open game, in the order they're listed.
+type tuiCandidate struct {
+	artStyle string
+	index    int
+}
+
+// RunTUI browses the games in the terminal until the user quits.
+func RunTUI(options Options) error {
+	browser, err := newArtworkBrowser(options)
+	if err != nil {
+		return err
+	}
+	tui := &artworkTUI{
+		artworkBrowser: browser,
+		input:          bufio.NewReader(os.Stdin),
+		games:          browser.sortedGames(),
+	}
+	restore, err := rawTerminal()
+	if err == nil {
+		tui.raw = true
+		defer restore()
+	}
+
+	selected := 0
+	for {
+		tui.drawList(selected)
+		key, ok := tui.readKey("Up/down or j/k to select, left/right or p/n to change page, enter to open, q to quit")
+		if !ok {
+			return nil
+		}
+		switch key {
+		case "q", keyEsc:
+			fmt.Print(tuiClearScreen)
+			return nil
+		case keyUp, "k":
+			selected--
+		case keyDown, "j":
+			selected++
+		case keyLeft, "p":
+			selected -= tuiPageSize
+		case keyRight, "n":
+			selected += tuiPageSize
+		case keyEnter, "o":
+			// Opening a game continues with the next one when skipped.
+			for selected < len(tui.games) && tui.browseGame(tui.games[selected]) {
+				selected++
+			}
+		default:
+			tui.message = "Unknown key " + key
+		}
+		if selected >= len(tui.games) {
+			selected = len(tui.games) - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+	}
+}
+
+// Shows the help and reads a key, returning false on end of input.
+func (tui *artworkTUI) readKey(help string) (string, bool) {
+	if tui.message != "" {
+		fmt.Println(tui.message)
+		tui.message = ""
+	}
+	if !tui.raw {
+		fmt.Printf("%v\n> ", help)
+		line, err := tui.input.ReadString('\n')
+		if err != nil && line == "" {
+			return "", false
+		}
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" {
+			return keyEnter, true
+		}
+		return line, true
+	}
+
+	fmt.Print(help)
+	b, err := tui.input.ReadByte()
+	if err != nil {
+		return "", false
+	}
+	switch b {
+	case '\r', '\n', ' ':
+		return keyEnter, true
+	case 3:
+		// Ctrl+C, which doesn't stop the program in raw mode.
+		return "q", true
+	case 27:
+		// Arrow keys are sent as ESC [ A to ESC [ D, a lone ESC is the key.
+		if tui.input.Buffered() < 2 {
+			return keyEsc, true
+		}
+		tui.input.ReadByte()
+		b, _ = tui.input.ReadByte()
+		switch b {
+		case 'A':
+			return keyUp, true
+		case 'B':
+			return keyDown, true
+		case 'C':
+			return keyRight, true
+		case 'D':
+			return keyLeft, true
+		}
+		return keyEsc, true
+	}
+	return strings.ToLower(string(rune(b))), true
+}
+
+// Returns a status indicator per art style, the initial if the game has an
+// image for it or a dash otherwise.
+func (tui *artworkTUI) status(game *Game) string {
+	status := ""
+	for _, artStyle := range tui.sortedArtStyles() {
+		if tui.currentImagePath(game, artStyle) != "" {
+			status += artStyle[:1]
+		} else {
+			status += "-"
+		}
+	}
+	return status
+}
+
+func (tui *artworkTUI) drawList(selected int) {
+	page := selected / tuiPageSize
+	fmt.Print(tuiClearScreen)
+	fmt.Printf("steamgrid - %v games, page %v of %v\n", len(tui.games), page+1, (len(tui.games)+tuiPageSize-1)/tuiPageSize)
+	fmt.Printf("Art styles: %v\n\n", strings.Join(tui.sortedArtStyles(), ", "))
+	for i := page * tuiPageSize; i < len(tui.games) && i < (page+1)*tuiPageSize; i++ {
+		game := tui.games[i]
+		name := game.Name
+		if name == "" {
+			name = "unknown game with id " + game.ID
+		}
+		cursor := " "
+		if i == selected {
+			cursor = ">"
+		}
+		fmt.Printf("%v [%v] %v\n", cursor, tui.status(game), name)
+	}
+	fmt.Println()
+}
+
+// Shows a game until the user goes back. Returns true if the user skipped to
+// the next game.
+func (tui *artworkTUI) browseGame(game *Game) bool {
+	tui.candidates = map[string][]artworkCandidate{}
+	tui.search(game)
+	selected := 0
+	for {
+		listed := tui.listedCandidates()
+		tui.drawGame(game, selected)
+		key, ok := tui.readKey("Up/down or j/k to select, enter to pin the candidate, r to re-search, s to skip to the next game, b to go back")
+		if !ok {
+			return false
+		}
+		switch key {
+		case "b", keyEsc, keyLeft:
+			return false
+		case "s":
+			return true
+		case "r":
+			tui.search(game)
+			selected = 0
+		case keyUp, "k":
+			if selected > 0 {
+				selected--
+			}
+		case keyDown, "j":
+			if selected < len(listed)-1 {
+				selected++
+			}
+		case keyEnter:
+			if len(listed) == 0 {
+				continue
+			}
+			candidate := listed[selected]
+			err := tui.pick(game, candidate.artStyle, tui.candidates[candidate.artStyle][candidate.index].URL)
+			if err != nil {
+				tui.message = fmt.Sprintf("Failed to use the %v: %v", candidate.artStyle, err.Error())
+			} else {
+				tui.message = fmt.Sprintf("%v saved.", candidate.artStyle)
+			}
+		default:
+			tui.message = "Unknown key " + key
+		}
+	}
+}
+
+// Returns the candidates of all art styles in the order they're shown.
+func (tui *artworkTUI) listedCandidates() []tuiCandidate {
+	var listed []tuiCandidate
+	for _, artStyle := range tui.sortedArtStyles() {
+		for i := range tui.candidates[artStyle] {
+			listed = append(listed, tuiCandidate{artStyle, i})
+		}
+	}
+	return listed
+}
+
+func (tui *artworkTUI) search(game *Game) {
+	fmt.Printf("\nSearching artwork for %v...\n", game.Name)
+	for _, artStyle := range tui.sortedArtStyles() {
+		tui.candidates[artStyle] = tui.artworkBrowser.candidates(game, artStyle)
+	}
+}
+
+func (tui *artworkTUI) drawGame(game *Game, selected int) {
+	fmt.Print(tuiClearScreen)
+	fmt.Printf("%v (%v) [%v]\n", game.Name, game.ID, tui.status(game))
+	n := 0
+	for _, artStyle := range tui.sortedArtStyles() {
+		current := tui.currentImagePath(game, artStyle)
+		if current == "" {
+			current = "none"
+		}
+		fmt.Printf("\n%v, current: %v\n", artStyle, current)
+		if len(tui.candidates[artStyle]) == 0 {
+			fmt.Println("    No candidates found.")
+		}
+		for _, candidate := range tui.candidates[artStyle] {
+			cursor := " "
+			if n == selected {
+				cursor = ">"
+			}
+			fmt.Printf("%v %v: %v\n", cursor, candidate.Provider, candidate.URL)
+			n++
+		}
+	}
+	fmt.Println()
+}
@@ -1,10 +1,9 @@
-package main
+package steamgrid
 
 import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -19,6 +18,8 @@ type User struct {
 	SteamID32 string
 	SteamID64 string
 	Dir       string
+	// Grid dir with symlinks resolved, see resolveGridDir.
+	GridDir string
 }
 
 // Used to convert between SteamId32 and SteamId64.
@@ -58,18 +59,13 @@ func GetUsers(installationDir string) ([]User, error) {
 			return nil, err
 		}
 
-		// Makes sure the grid directory exists.
-		gridDir := filepath.Join(userDir, "config", "grid")
-		err = os.MkdirAll(gridDir, 0777)
+		gridDir, err := resolveGridDir(userDir)
 		if err != nil {
 			return nil, err
 		}
-
-		// The Linux version of Steam ships with the "grid" dir without executable bit.
-		// This in turn denies permission to everything inside the folder. This line is
-		// here to ensure we have the correct permission.
-		fmt.Println("Setting permission...")
-		os.Chmod(gridDir, 0777)
+		if gridDir != filepath.Join(userDir, "config", "grid") {
+			fmt.Println("Using grid folder " + gridDir)
+		}
 
 		pattern := regexp.MustCompile(`"PersonaName"\s*"(.+?)"`)
 		username := pattern.FindStringSubmatch(string(configBytes))[1]
@@ -77,7 +73,8 @@ func GetUsers(installationDir string) ([]User, error) {
 		steamID32, err := strconv.ParseInt(userID, 10, 64)
 		steamID64 := steamID32 + idConversionConstant
 		strSteamID64 := strconv.FormatInt(steamID64, 10)
-		users = append(users, User{username, userID, strSteamID64, userDir})
+		users = append(users, User{username, userID, strSteamID64, userDir, gridDir})
+		gridDirUsers[gridDir] = userID
 	}
 
 	return users, nil
@@ -94,7 +91,7 @@ const steamProfileErrorMessage = `The specified profile could not be found.`
 
 // GetProfile returns the HTML profile from a user from their SteamId32.
 func GetProfile(user User) (string, error) {
-	response, err := http.Get(fmt.Sprintf(profilePermalinkFormat, user.SteamID64))
+	response, err := httpClient.Get(fmt.Sprintf(profilePermalinkFormat, user.SteamID64))
 	if err != nil {
 		return "", err
 	}
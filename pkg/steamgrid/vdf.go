@@ -0,0 +1,136 @@
+package steamgrid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Value types of binary VDF files, like shortcuts.vdf.
+const (
+	vdfMap    = 0x00
+	vdfString = 0x01
+	vdfInt    = 0x02
+	vdfEnd    = 0x08
+)
+
+// A value of a binary VDF file. Maps keep their children in order, since
+// Steam numbers the shortcuts by position. Other values are kept as raw
+// bytes, so files are written back unchanged.
+type vdfNode struct {
+	Type     byte
+	Name     string
+	Value    []byte
+	Children []*vdfNode
+}
+
+func newVdfString(name string, value string) *vdfNode {
+	return &vdfNode{vdfString, name, []byte(value), nil}
+}
+
+func newVdfInt(name string, value uint32) *vdfNode {
+	valueBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(valueBytes, value)
+	return &vdfNode{vdfInt, name, valueBytes, nil}
+}
+
+func newVdfMap(name string, children ...*vdfNode) *vdfNode {
+	return &vdfNode{vdfMap, name, nil, children}
+}
+
+// Returns the child with the name, ignoring case like Steam, or nil.
+func (n *vdfNode) child(name string) *vdfNode {
+	for _, child := range n.Children {
+		if strings.EqualFold(child.Name, name) {
+			return child
+		}
+	}
+	return nil
+}
+
+// Parses a binary VDF file into a map holding its top level values.
+func parseVdf(data []byte) (*vdfNode, error) {
+	children, _, err := readVdfChildren(data)
+	if err != nil {
+		return nil, err
+	}
+	return newVdfMap("", children...), nil
+}
+
+// Reads values until the end of their map, returning the rest of the data.
+func readVdfChildren(data []byte) ([]*vdfNode, []byte, error) {
+	errTruncated := errors.New("Truncated VDF file")
+	var children []*vdfNode
+	for {
+		if len(data) == 0 {
+			return nil, nil, errTruncated
+		}
+		valueType := data[0]
+		data = data[1:]
+		if valueType == vdfEnd {
+			return children, data, nil
+		}
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			return nil, nil, errTruncated
+		}
+		node := &vdfNode{Type: valueType, Name: string(data[:end])}
+		data = data[end+1:]
+
+		switch valueType {
+		case vdfMap:
+			var err error
+			node.Children, data, err = readVdfChildren(data)
+			if err != nil {
+				return nil, nil, err
+			}
+		case vdfString:
+			end := bytes.IndexByte(data, 0)
+			if end < 0 {
+				return nil, nil, errTruncated
+			}
+			node.Value = data[:end]
+			data = data[end+1:]
+		case vdfInt, 0x03, 0x04, 0x06, 0x07, 0x0a:
+			size := 4
+			if valueType == 0x07 || valueType == 0x0a {
+				size = 8
+			}
+			if len(data) < size {
+				return nil, nil, errTruncated
+			}
+			node.Value = data[:size]
+			data = data[size:]
+		default:
+			return nil, nil, fmt.Errorf("Unknown value type %v in VDF file", valueType)
+		}
+		children = append(children, node)
+	}
+}
+
+// Encodes the values of a map as a binary VDF file.
+func encodeVdf(root *vdfNode) []byte {
+	var buf bytes.Buffer
+	writeVdfChildren(&buf, root.Children)
+	return buf.Bytes()
+}
+
+func writeVdfChildren(buf *bytes.Buffer, children []*vdfNode) {
+	for _, child := range children {
+		buf.WriteByte(child.Type)
+		buf.WriteString(child.Name)
+		buf.WriteByte(0)
+		switch child.Type {
+		case vdfMap:
+			writeVdfChildren(buf, child.Children)
+		case vdfString:
+			buf.Write(child.Value)
+			buf.WriteByte(0)
+		default:
+			buf.Write(child.Value)
+		}
+	}
+	buf.WriteByte(vdfEnd)
+}
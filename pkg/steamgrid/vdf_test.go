@@ -0,0 +1,82 @@
+package steamgrid
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A shortcuts.vdf as written by the Steam client, with one shortcut and a
+// 64 bit value, which must be kept as it is.
+var shortcutsVdf = []byte("\x00shortcuts\x00" +
+	"\x000\x00" +
+	"\x02appid\x00\x39\x30\x00\x80" +
+	"\x01AppName\x00Some Game\x00" +
+	"\x01Exe\x00\"C:\\Games\\Some Game\\game.exe\"\x00" +
+	"\x01StartDir\x00\"C:\\Games\\Some Game\"\x00" +
+	"\x07LastPlayTime64\x00\x01\x02\x03\x04\x05\x06\x07\x08" +
+	"\x00tags\x00\x010\x00favorite\x00\x08" +
+	"\x08" +
+	"\x08" +
+	"\x08")
+
+func TestVdfRoundTrip(t *testing.T) {
+	root, err := parseVdf(shortcutsVdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortcut := root.child("shortcuts").child("0")
+	if shortcut == nil || string(shortcut.child("appname").Value) != "Some Game" {
+		t.Fatalf("shortcut not parsed: %+v", root)
+	}
+	if encoded := encodeVdf(root); !bytes.Equal(encoded, shortcutsVdf) {
+		t.Errorf("encoded file differs:\n%q\n%q", encoded, shortcutsVdf)
+	}
+}
+
+func TestVdfTruncated(t *testing.T) {
+	for i := 0; i < len(shortcutsVdf)-1; i++ {
+		if _, err := parseVdf(shortcutsVdf[:i]); err == nil {
+			t.Fatalf("no error for the first %v bytes", i)
+		}
+	}
+}
+
+func TestAddShortcutsKeepsExisting(t *testing.T) {
+	userDir, err := ioutil.TempDir("", "steamgrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(userDir)
+	shortcutsPath := filepath.Join(userDir, "config", "shortcuts.vdf")
+	os.MkdirAll(filepath.Dir(shortcutsPath), 0777)
+	err = ioutil.WriteFile(shortcutsPath, shortcutsVdf, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	launchers := []launcher{{"Some Game", `C:\Games\Some Game\game.exe`}, {"Other Game", `C:\Games\Other Game\other.exe`}}
+	added, err := addShortcuts(User{Dir: userDir}, launchers)
+	if err != nil || added != 1 {
+		t.Fatalf("added %v shortcuts, error %v", added, err)
+	}
+
+	written, err := ioutil.ReadFile(shortcutsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := parseVdf(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortcuts := root.child("shortcuts").Children
+	if len(shortcuts) != 2 || string(shortcuts[1].child("AppName").Value) != "Other Game" {
+		t.Fatalf("unexpected shortcuts: %+v", shortcuts)
+	}
+	// The original shortcut is written back byte for byte.
+	if !bytes.HasPrefix(written, shortcutsVdf[:len(shortcutsVdf)-2]) {
+		t.Errorf("existing shortcut changed:\n%q", written)
+	}
+}
@@ -0,0 +1,119 @@
+package steamgrid
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kettek/apng"
+	_ "golang.org/x/image/webp"
+)
+
+// Returns an error if the image can't be fully decoded, for example because
+// it was truncated.
+func checkImage(imageBytes []byte) error {
+	if len(imageBytes) == 0 {
+		return errors.New("empty file")
+	}
+
+	// Decode all frames of animations, a truncated file may still have a valid
+	// first frame.
+	if _, err := apng.DecodeAll(bytes.NewBuffer(imageBytes)); err == nil {
+		return nil
+	}
+	if _, err := gif.DecodeAll(bytes.NewBuffer(imageBytes)); err == nil {
+		return nil
+	}
+	if sniffImageExt(imageBytes) == ".webp" && isAnimated(imageBytes) {
+		// There's no decoder for all the frames, but a truncated file fails
+		// the chunk check.
+		if err := checkWebPChunks(imageBytes); err != nil {
+			return err
+		}
+		_, err := decodeFirstFrame(imageBytes)
+		return err
+	}
+	_, _, err := image.Decode(bytes.NewBuffer(imageBytes))
+	return err
+}
+
+// Checks all images in the grid dir and its backups, removing the ones that
+// can't be decoded and temporary files left over from interrupted writes.
+// Removed images are restored from their backup or downloaded again by the
+// normal run. Returns the removed paths.
+func verifyGridDir(gridDir string) ([]string, error) {
+	var removed []string
+	for _, dir := range []string{gridDir, originalsDir(gridDir)} {
+		files, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			// No backups yet, e.g. with --no-backup.
+			continue
+		} else if err != nil {
+			return removed, err
+		}
+
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, file.Name())
+
+			if strings.HasPrefix(file.Name(), ".") && strings.HasSuffix(file.Name(), ".tmp") {
+				// Leftover from writeFileAtomic.
+			} else if len(filterForImages([]string{path})) == 0 {
+				continue
+			} else {
+				imageBytes, _, err := readImageFile(path)
+				if err == nil && checkImage(imageBytes) == nil {
+					continue
+				}
+			}
+
+			err = os.Remove(path)
+			if err != nil {
+				return removed, err
+			}
+			removed = append(removed, path)
+		}
+	}
+	return removed, nil
+}
+
+// Checks the backups recorded in the manifest against their hashes, removing
+// the ones that don't match because of bit rot or editing by hand. Without
+// its backup the image is downloaded again by the normal run. Returns the
+// removed paths.
+func verifyBackups(gridDir string, manifest *Manifest) ([]string, error) {
+	var removed []string
+	for key, entry := range manifest.Entries {
+		var backups []string
+		if entry.Backup != "" {
+			backups = []string{filepath.Join(originalsDir(gridDir), entry.Backup)}
+		} else {
+			// Written before the manifest recorded backups.
+			backups, _ = filepath.Glob(filepath.Join(originalsDir(gridDir), key+" "+entry.Hash+".*"))
+			backups = filterForImages(backups)
+		}
+
+		for _, path := range backups {
+			imageBytes, _, err := readImageFile(path)
+			if os.IsNotExist(err) {
+				continue
+			} else if err == nil && imageHash(imageBytes) == entry.CleanHash {
+				continue
+			}
+
+			err = os.Remove(path)
+			if err != nil {
+				return removed, err
+			}
+			removed = append(removed, path)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,45 @@
+package steamgrid
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Summary of a run, posted to the webhook when it finishes.
+type runSummary struct {
+	Downloaded      int     `json:"downloaded"`
+	OverlaysApplied int     `json:"overlaysApplied"`
+	Changed         int     `json:"changed"`
+	NotFound        int     `json:"notFound"`
+	Failed          int     `json:"failed"`
+	Status          string  `json:"status"`
+	Seconds         float64 `json:"seconds"`
+}
+
+// Posts the run summary to a webhook. The message is sent both as "content"
+// and "text", so it shows up in Discord and Slack, with the numbers in
+// "summary" for other receivers.
+func postWebhook(url string, summary runSummary) error {
+	message := fmt.Sprintf("steamgrid finished (%v) in %v: %v images downloaded, %v overlays applied, %v changed, %v not found, %v failed.",
+		summary.Status, (time.Duration(summary.Seconds) * time.Second).String(), summary.Downloaded, summary.OverlaysApplied, summary.Changed, summary.NotFound, summary.Failed)
+	body, err := json.Marshal(map[string]interface{}{
+		"content": message,
+		"text":    message,
+		"summary": summary,
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("webhook returned status %v", response.Status)
+	}
+	return nil
+}
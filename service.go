@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Name of the scheduled task and systemd units.
+const serviceName = "steamgrid"
+
+const systemdServiceFormat = `[Unit]
+Description=Download Steam grid images with steamgrid
+
+[Service]
+Type=oneshot
+ExecStart=%v
+`
+
+const systemdTimerFormat = `[Unit]
+Description=Run steamgrid weekly
+
+[Timer]
+OnCalendar=weekly
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+
+// Flags holding paths, made absolute for the scheduled runs, which don't
+// start in the current folder.
+var pathFlags = []string{"steamdir", "backup-dir", "artstyles", "tag-aliases", "app-map", "retroarch-playlists", "preview", "trace"}
+
+// Longest command Task Scheduler accepts for /TR.
+const maxTaskCommandLength = 261
+
+// Returns the command line arguments without the service flags and the
+// secret flags, which the scheduled run reads from the keychain instead, plus
+// the batch flag so scheduled runs never wait for input. The subcommand stays
+// first and relative paths are made absolute.
+func serviceArgs(args []string) ([]string, error) {
+	var result []string
+	if len(args) > 0 && findCommand(args[0]) != nil {
+		result = append(result, args[0])
+		args = args[1:]
+	}
+	result = append(result, "--batch")
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			// The steam dir given without a flag.
+			path, err := filepath.Abs(arg)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, path)
+			continue
+		}
+		parts := strings.SplitN(arg, "=", 2)
+		name := strings.TrimLeft(parts[0], "-")
+		// Whether the value is the next argument.
+		separateValue := len(parts) == 1 && !isBoolFlag(name)
+		if name == "install-service" || name == "uninstall-service" || name == "batch" {
+			continue
+		}
+		if isSecretFlag(name) {
+			if separateValue {
+				i++
+			}
+			continue
+		}
+		if !isPathFlag(name) {
+			result = append(result, arg)
+			if separateValue && i+1 < len(args) {
+				i++
+				result = append(result, args[i])
+			}
+			continue
+		}
+		value := ""
+		if len(parts) == 2 {
+			value = parts[1]
+		} else if i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if value != "" {
+			path, err := filepath.Abs(value)
+			if err != nil {
+				return nil, err
+			}
+			value = path
+		}
+		result = append(result, parts[0]+"="+value)
+	}
+	return result, nil
+}
+
+// Returns true if the flag takes no value, like --batch.
+func isBoolFlag(name string) bool {
+	f := flag.Lookup(name)
+	if f == nil {
+		return false
+	}
+	boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && boolFlag.IsBoolFlag()
+}
+
+// Returns true if the flag holds a path.
+func isPathFlag(name string) bool {
+	for _, pathFlag := range pathFlags {
+		if name == pathFlag {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the arguments give a key or password.
+func hasSecretArgs(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && isSecretFlag(strings.TrimLeft(strings.SplitN(arg, "=", 2)[0], "-")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the flag holds a key or password.
+func isSecretFlag(name string) bool {
+	for _, secret := range secretFlags {
+		if name == secret {
+			return true
+		}
+	}
+	return false
+}
+
+// Quotes an argument for a systemd ExecStart line or a Windows command line.
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"") {
+		return arg
+	}
+	return `"` + strings.Replace(arg, `"`, `\"`, -1) + `"`
+}
+
+func systemdUnitDir() (string, error) {
+	currentUser, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(currentUser.HomeDir, ".config", "systemd", "user"), nil
+}
+
+// Registers a weekly scheduled run of steamgrid with the given arguments:
+// a Task Scheduler task on Windows, a systemd user timer on Linux.
+func installService(args []string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args, err = serviceArgs(args)
+	if err != nil {
+		return err
+	}
+	command := quoteArg(executable)
+	for _, arg := range args {
+		command += " " + quoteArg(arg)
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		if len(command) > maxTaskCommandLength {
+			return fmt.Errorf("The command is %v characters long, but Task Scheduler only accepts %v. Move the options to the config file with 'steamgrid setup' or use fewer flags", len(command), maxTaskCommandLength)
+		}
+		return exec.Command("schtasks", "/Create", "/F", "/SC", "WEEKLY", "/TN", serviceName, "/TR", command).Run()
+	case "linux":
+		unitDir, err := systemdUnitDir()
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(unitDir, 0777)
+		if err != nil {
+			return err
+		}
+		// systemd expands % specifiers in ExecStart.
+		execStart := strings.Replace(command, "%", "%%", -1)
+		err = ioutil.WriteFile(filepath.Join(unitDir, serviceName+".service"), []byte(fmt.Sprintf(systemdServiceFormat, execStart)), 0600)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(filepath.Join(unitDir, serviceName+".timer"), []byte(systemdTimerFormat), 0666)
+		if err != nil {
+			return err
+		}
+		err = exec.Command("systemctl", "--user", "daemon-reload").Run()
+		if err != nil {
+			return err
+		}
+		return exec.Command("systemctl", "--user", "enable", "--now", serviceName+".timer").Run()
+	default:
+		return errors.New("Scheduled runs are only supported on Windows and Linux")
+	}
+}
+
+// Removes the scheduled run registered by installService.
+func uninstallService() error {
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("schtasks", "/Delete", "/F", "/TN", serviceName).Run()
+	case "linux":
+		// The timer may already be gone, the files are what matters.
+		exec.Command("systemctl", "--user", "disable", "--now", serviceName+".timer").Run()
+		unitDir, err := systemdUnitDir()
+		if err != nil {
+			return err
+		}
+		for _, unit := range []string{serviceName + ".service", serviceName + ".timer"} {
+			err = os.Remove(filepath.Join(unitDir, unit))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return exec.Command("systemctl", "--user", "daemon-reload").Run()
+	default:
+		return errors.New("Scheduled runs are only supported on Windows and Linux")
+	}
+}
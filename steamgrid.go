@@ -1,346 +1,307 @@
-// Automatically downloads and configures Steam grid images for all games in a
-// given Steam installation.
+// Command steamgrid automatically downloads and configures Steam grid images
+// for all games in a given Steam installation. The work is done by the
+// pkg/steamgrid package, this is the command line on top.
 package main
 
 import (
 	"bufio"
-	"errors"
+	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"os"
-	"path/filepath"
-	"strconv"
 	"time"
+
+	"github.com/boppreh/steamgrid/pkg/steamgrid"
+)
+
+// Exit codes, so scripts can tell what happened.
+const (
+	exitSuccess = 0
+	// Couldn't even start, e.g. Steam wasn't found.
+	exitFatal = 1
+	// Some images could not be found or processed.
+	exitPartial = 2
+	// An API key or login was rejected.
+	exitAuth = 3
+	// The run took longer than --global-timeout.
+	exitTimeout = 4
 )
 
-// Prints an error and quits.
-func errorAndExit(err error) {
+// Time after --global-timeout the process is killed if the run is still
+// going, longer than any request timeout.
+const killGracePeriod = 5 * time.Minute
+
+// Returns the exit code for an error returned by Run.
+func exitCode(err error) int {
+	switch err {
+	case nil:
+		return exitSuccess
+	case steamgrid.ErrPartialFailure:
+		return exitPartial
+	case steamgrid.ErrAuthentication:
+		return exitAuth
+	case steamgrid.ErrTimeout:
+		return exitTimeout
+	default:
+		return exitFatal
+	}
+}
+
+// Prints an error and quits with the given exit code.
+func errorAndExit(err error, code int) {
 	fmt.Println(err.Error())
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
-	os.Exit(0)
+	os.Exit(code)
 }
 
 func main() {
-	http.DefaultTransport.(*http.Transport).ResponseHeaderTimeout = time.Second * 10
 	startApplication()
 }
 
 func startApplication() {
-	steamGridDBApiKey := flag.String("steamgriddb", "", "Your personal SteamGridDB api key, get one here: https://www.steamgriddb.com/profile/preferences")
-	IGDBSecret := flag.String("igdbsecret", "", "Your personal IGDB api key, get one here: https://api.igdb.com/signup")
-	IGDBClient := flag.String("igdbclient", "", "Your personal IGDB api key, get one here: https://api.igdb.com/signup")
-	steamDir := flag.String("steamdir", "", "Path to your steam installation")
+	var options steamgrid.Options
+	installServiceFlag := flag.Bool("install-service", false, "Run steamgrid weekly in the background with the other flags given (Windows and Linux)")
+	uninstallServiceFlag := flag.Bool("uninstall-service", false, "Stop running steamgrid weekly in the background")
+	batch := flag.Bool("batch", false, "Never wait for input, for scheduled runs")
+	getOverlays := flag.String("get-overlays", "", "Download an overlay pack by zip URL or name (\"default\" for the built-in overlays) into the overlays folder, then exit")
+	diff := flag.Bool("diff", false, "Print which images the last run added, removed or changed, and the ones changed since, without modifying anything")
+	rollback := flag.String("rollback", "", "Put the grid images back to how the run at the given time left them, e.g. 2024-05-01T20-15, then exit")
+	pruneBackupsFlag := flag.Bool("prune-backups", false, "Remove old backups of images and backups of games no longer in the library, then exit")
+	keepBackups := flag.Int("keep-backups", 1, "Number of backups to keep for each game and art style with --prune-backups")
+	tui := flag.Bool("tui", false, "Browse the games in the terminal to review and pick their artwork")
+	startGUI := flag.Bool("gui", false, "Open a page in the browser to set the main options and run. The default on Windows when started by double clicking")
+	saveKeysFlag := flag.Bool("save-keys", false, "Check the api keys and passwords given and save them in the OS keychain for later runs, then exit")
+	serve := flag.String("serve", "", "Serve a web page on the given address, e.g. \"localhost:8080\", to review and pick the artwork of each game")
+	flag.StringVar(&options.SteamGridDBApiKey, "steamgriddb", "", "Your personal SteamGridDB api key, get one here: https://www.steamgriddb.com/profile/preferences")
+	flag.StringVar(&options.IGDBSecret, "igdbsecret", "", "Your personal IGDB api key, get one here: https://api.igdb.com/signup")
+	flag.StringVar(&options.IGDBClient, "igdbclient", "", "Your personal IGDB api key, get one here: https://api.igdb.com/signup")
+	flag.StringVar(&options.SteamDir, "steamdir", "", "Path to your steam installation")
 	// "alternate" "blurred" "white_logo" "material" "no_logo"
-	steamGridDBStyles := flag.String("styles", "alternate", "Comma separated list of styles to download from SteamGridDB.\nExample: \"white_logo,material\"")
-	steamGridDBLogoStyles := flag.String("logostyles", "official", "Comma separated list of styles to download from SteamGridDB.\nExample: \"white,black\"")
+	flag.StringVar(&options.SteamGridDBStyles, "styles", "alternate", "Comma separated list of styles to download from SteamGridDB.\nExample: \"white_logo,material\"")
+	flag.StringVar(&options.SteamGridDBLogoStyles, "logostyles", "official", "Comma separated list of styles to download from SteamGridDB.\nExample: \"white,black\"")
+	flag.BoolVar(&options.PairHeroLogo, "pair-hero-logo", false, "Prefer a SteamGridDB hero and logo made by the same author, so they match")
 	// "static" "animated"
-	steamGridDBTypes := flag.String("types", "static", "Comma separated list of types to download from SteamGridDB.\nExample: \"static,animated\"")
-	steamGridDBNsfw := flag.String("nsfw", "false", "Set to false to filter out nsfw, true to only include nsfw, any to include both.")
-	steamGridDBHumor := flag.String("humor", "false", "Set to false to filter out humor, true to only include humor, any to include both.")
-	steamGridDBBannerDimensions := flag.String("bannerdimensions", "460x215,920x430", "Filter results by image dimensions. Multiple dimensions can be provided as comma seperated strings.")
-	steamGridDBCoverDimensions := flag.String("coverdimensions", "600x900,342x482,660x930", "Filter results by image dimensions. Multiple dimensions can be provided as comma seperated strings.")
-	steamGridDBHeroDimensions := flag.String("herodimensions", "1920x620,3840x1240,1600x650", "Filter results by image dimensions. Multiple dimensions can be provided as comma seperated strings.")
-	skipSteam := flag.Bool("skipsteam", false, "Skip downloads from Steam servers")
-	skipGoogle := flag.Bool("skipgoogle", false, "Skip search and downloads from google")
-	skipBanner := flag.Bool("skipbanner", false, "Skip search and processing banner artwork")
-	skipCover := flag.Bool("skipcover", false, "Skip search and processing cover artwork")
-	skipHero := flag.Bool("skiphero", false, "Skip search and processing hero artwork")
-	skipLogo := flag.Bool("skiplogo", false, "Skip search and processing logo artwork")
-	nonSteamOnly := flag.Bool("nonsteamonly", false, "Only search artwork for Non-Steam-Games")
-	appIDs := flag.String("appids", "", "Comma separated list of appIds that should be processed")
-	onlyMissingArtwork := flag.Bool("onlymissingartwork", false, "Only download artworks missing on the official servers")
-	flag.Parse()
-	if flag.NArg() == 1 {
-		steamDir = &flag.Args()[0]
-	} else if flag.NArg() >= 2 {
-		flag.Usage()
-		os.Exit(1)
+	flag.StringVar(&options.SteamGridDBTypes, "types", "static", "Comma separated list of types to download from SteamGridDB.\nExample: \"static,animated\"")
+	flag.StringVar(&options.SteamGridDBNsfw, "nsfw", "false", "Set to false to filter out nsfw, true to only include nsfw, any to include both.")
+	flag.StringVar(&options.SteamGridDBHumor, "humor", "false", "Set to false to filter out humor, true to only include humor, any to include both.")
+	flag.StringVar(&options.SteamGridDBBannerDimensions, "bannerdimensions", "460x215,920x430", "Filter results by image dimensions. Multiple dimensions can be provided as comma seperated strings.")
+	flag.StringVar(&options.SteamGridDBCoverDimensions, "coverdimensions", "600x900,342x482,660x930", "Filter results by image dimensions. Multiple dimensions can be provided as comma seperated strings.")
+	flag.StringVar(&options.SteamGridDBHeroDimensions, "herodimensions", "1920x620,3840x1240,1600x650", "Filter results by image dimensions. Multiple dimensions can be provided as comma seperated strings.")
+	flag.StringVar(&options.HeroSize, "hero-size", "both", "Hero sizes to download: both, or small to prefer the 1920px wide heroes and scale down bigger ones, for displays under 4K")
+	flag.StringVar(&options.SteamGridDBLogoDimensions, "logodimensions", "", "Filter logo results by image dimensions. Multiple dimensions can be provided as comma seperated strings.")
+	flag.StringVar(&options.SteamGridDBBannerMimes, "bannermimes", "", "Filter banner results by file type, e.g. \"image/webp\". Multiple types can be provided as comma seperated strings.")
+	flag.StringVar(&options.SteamGridDBCoverMimes, "covermimes", "", "Filter cover results by file type, e.g. \"image/webp\". Multiple types can be provided as comma seperated strings.")
+	flag.StringVar(&options.SteamGridDBHeroMimes, "heromimes", "", "Filter hero results by file type, e.g. \"image/png,image/jpeg\". Multiple types can be provided as comma seperated strings.")
+	flag.StringVar(&options.SteamGridDBLogoMimes, "logomimes", "", "Filter logo results by file type, e.g. \"image/png\". Multiple types can be provided as comma seperated strings.")
+	flag.StringVar(&options.ArtStylesFile, "artstyles", "", "JSON file defining extra art styles, for new asset types Steam adds")
+	flag.StringVar(&options.TagAliasesFile, "tag-aliases", "", "JSON file with regex rules renaming categories before looking up their overlays")
+	flag.StringVar(&options.DimCategories, "dim", "", "Comma separated categories whose artwork is darkened and desaturated, without an overlay image")
+	flag.Float64Var(&options.DimStrength, "dim-strength", 0.6, "How much --dim darkens the artwork, from 0 to 1")
+	flag.StringVar(&options.Borders, "border", "", "Comma separated category=#RRGGBB:widthpx frames drawn on the artwork, e.g. Favorites=#FFD700:12px")
+	flag.StringVar(&options.Effects, "effect", "", "Comma separated category[.style]:effect(amount) image effects, e.g. Completed:grayscale(0.8)")
+	flag.BoolVar(&options.ScreenshotFallback, "screenshot-fallback", false, "Use a cropped store screenshot for games without any artwork")
+	flag.BoolVar(&options.ParentFallback, "parent-fallback", false, "Use the artwork of the parent game for DLCs, soundtracks and tools without their own")
+	flag.BoolVar(&options.Trailers, "trailers", false, "Experimental: turn store trailers into animated banners and heroes when no animated artwork is found. Needs ffmpeg")
+	flag.StringVar(&options.Preview, "preview", "", "Don't change anything, save a page with the current and new artwork of the images that would change to the given file, e.g. preview.html")
+	flag.BoolVar(&options.Confirm, "confirm", false, "Ask for confirmation after showing what the run will do")
+	flag.BoolVar(&options.SkipSteam, "skipsteam", false, "Skip downloads from Steam servers")
+	flag.BoolVar(&options.SkipGoogle, "skipgoogle", false, "Skip search and downloads from google")
+	flag.StringVar(&options.SearchEngine, "search-engine", "google", "Image search engine used as last resort for banners: google, bing or duckduckgo")
+	flag.StringVar(&options.SearchSize, "search-size", "exact", "Size of the images searched for banners: exact, or larger to also accept bigger images, cropped to fit")
+	flag.BoolVar(&options.SkipStores, "skipstores", false, "Skip searching GOG and the Epic Games Store for non-Steam games")
+	flag.BoolVar(&options.SkipBanner, "skipbanner", false, "Skip search and processing banner artwork")
+	flag.BoolVar(&options.SkipCover, "skipcover", false, "Skip search and processing cover artwork")
+	flag.BoolVar(&options.SkipHero, "skiphero", false, "Skip search and processing hero artwork")
+	flag.BoolVar(&options.SkipLogo, "skiplogo", false, "Skip search and processing logo artwork")
+	flag.StringVar(&options.RetroArchPlaylists, "retroarch-playlists", "", "Folder of the RetroArch playlists used to name and tag emulator shortcuts (default: RetroArch's own folder, if found)")
+	flag.BoolVar(&options.NonSteamOnly, "nonsteamonly", false, "Only search artwork for Non-Steam-Games")
+	flag.BoolVar(&options.SkipHidden, "skip-hidden", false, "Skip games in Steam's hidden collection")
+	flag.StringVar(&options.ExcludeTypes, "exclude-types", "", "Comma separated types of Steam apps to skip: dlc, demo, soundtrack, video, tool or server")
+	flag.StringVar(&options.AppIDs, "appids", "", "Comma separated list of appIds that should be processed")
+	flag.BoolVar(&options.EnglishNames, "english-names", false, "Search external providers with the English name of games whose Steam name is localized, e.g. in Japanese")
+	flag.StringVar(&options.CleanNames, "clean-names", steamgrid.DefaultNameCleaners, "Comma separated steps to clean non-Steam game names before searching: extension, region, tags, trademark, or none")
+	flag.StringVar(&options.AppMapFile, "app-map", "", "JSON file mapping the IDs of games that are never found, like mods and playtests, to the name to search for or to the appID of a parent game")
+	flag.BoolVar(&options.OnlyMissingArtwork, "onlymissingartwork", false, "Only download artworks missing on the official servers")
+	flag.StringVar(&options.ScreenScraperDevID, "screenscraperdevid", "", "Your ScreenScraper developer id, used to find artwork for emulated non-Steam games")
+	flag.StringVar(&options.ScreenScraperDevPassword, "screenscraperdevpassword", "", "Your ScreenScraper developer password")
+	flag.StringVar(&options.ScreenScraperUser, "screenscraperuser", "", "Your ScreenScraper user name, optional but raises the request quota")
+	flag.StringVar(&options.ScreenScraperPassword, "screenscraperpassword", "", "Your ScreenScraper user password")
+	flag.IntVar(&options.Candidates, "candidates", 0, "Save thumbnails of the top N SteamGridDB candidates for games with an ambiguous name match into the 'candidates' folder")
+	flag.BoolVar(&options.NoOverwrite, "no-overwrite", false, "Leave every existing grid image untouched, only add missing ones")
+	flag.StringVar(&options.Refresh, "refresh", "", "Download again and replace the images that came from these comma separated sources (e.g. steamgriddb), or all images with \"all\"")
+	flag.StringVar(&options.Pins, "pin", "", "Comma separated appid=type:id images to use as is, e.g. 252950=grid:123456 for a SteamGridDB asset or 252950=hero:https://... for an image URL")
+	flag.BoolVar(&options.OverlayOnly, "overlay-only", false, "Don't download anything, only reapply the category overlays to the existing images")
+	flag.BoolVar(&options.NoOverlays, "no-overlays", false, "Only download artwork, never apply category overlays")
+	flag.StringVar(&options.MaxBandwidth, "max-bandwidth", "", "Limit the download rate, e.g. \"5MB/s\" or \"500KB/s\"")
+	flag.StringVar(&options.Timeouts, "timeouts", "", "Comma separated timeouts for whole requests of each provider or of image downloads, e.g. \"search=5s,download=1m\"")
+	flag.StringVar(&options.GlobalTimeout, "global-timeout", "", "Stop the run after this long, e.g. \"30m\", saving what was done so far")
+	flag.BoolVar(&options.RetryNotFound, "retry-notfound", false, "Search again for the images that weren't found anywhere in the last 30 days")
+	flag.StringVar(&options.PprofAddr, "pprof", "", "Serve Go's pprof profiles on the given address during the run, e.g. \":6060\"")
+	flag.StringVar(&options.TraceFile, "trace", "", "Write a Go runtime trace of the run to the given file")
+	flag.StringVar(&options.MaxMemory, "max-memory", "", "Limit the memory used by images processed at the same time, e.g. 2G")
+	flag.StringVar(&options.Dedup, "dedup", "off", "Link identical images across users and Big Picture copies to save space: off or hardlink")
+	flag.BoolVar(&options.NoLegacy, "no-legacy", false, "Don't write the extra copies named with the legacy and signed IDs used by Big Picture mode and some clients, removing existing ones")
+	flag.BoolVar(&options.CompressBackups, "compress-backups", false, "Store the backups of original images gzip-compressed, mostly useful for large animations")
+	flag.BoolVar(&options.NoBackup, "no-backup", false, "Don't keep backups of the original images. Saves space and time, but images can't be restored and overlays can't be changed without downloading them again")
+	flag.StringVar(&options.BackupDir, "backup-dir", "", "Folder for the backups of original images, with a subfolder per user, instead of 'originals' inside the grid folder")
+	flag.BoolVar(&options.Verify, "verify", false, "Check all existing images and backups, replacing corrupt or truncated ones")
+	flag.BoolVar(&options.VerifyBackups, "verify-backups", false, "Check the backups against the hashes in the manifest, downloading the images of damaged or modified ones again")
+	flag.BoolVar(&options.Plain, "plain", false, "Print one line per game and image instead of a progress bar, for logs")
+	flag.StringVar(&options.MetricsAddr, "metrics", "", "Serve Prometheus metrics at /metrics on the given address during the run, e.g. \":9090\"")
+	flag.StringVar(&options.WebhookURL, "webhook-url", "", "Post a summary of the run to this webhook when done, e.g. a Discord or Slack webhook")
+	flag.BoolVar(&options.CloseSteam, "close-steam", false, "Close Steam before writing images and reopen it when done")
+	flag.BoolVar(&options.WaitSteam, "wait-steam", false, "Wait for Steam to be closed before writing images")
+	flag.BoolVar(&options.PreserveCustom, "preserve-custom", false, "Leave images set by hand in Steam untouched. Without this flag they are kept but get overlays applied")
+	flag.StringVar(&options.TargetFormats, "target-formats", "png,jpg,gif,webp", "Comma separated image formats your Steam client shows, others are converted to png or jpg")
+	flag.StringVar(&options.OutputFormats, "output-formats", "", "Comma separated style=format pairs choosing the format written for each art style: png, jpg or source, e.g. hero=jpg,cover=source")
+	flag.IntVar(&options.JPEGQuality, "jpeg-quality", 95, "Quality of the JPEGs written after applying overlays or converting, from 1 to 100")
+	flag.StringVar(&options.PNGCompression, "png-compression", "default", "Compression of the PNGs written after applying overlays or converting: default, none, fast or best")
+	flag.StringVar(&options.AnimatedFormat, "animated-format", "apng", "Output format for animated artwork: apng (keeps downloaded WebPs as they are) or gif")
+	flag.Usage = printUsage
+
+	args := os.Args[1:]
+	var cmd *command
+	if len(args) > 0 {
+		cmd = findCommand(args[0])
 	}
-
-	// Process command line flags
-	steamGridDBBannerFilter := "?styles=" + *steamGridDBStyles + "&types=" + *steamGridDBTypes + "&nsfw=" + *steamGridDBNsfw + "&humor=" + *steamGridDBHumor + "&dimensions=" + *steamGridDBBannerDimensions
-	steamGridDBCoverFilter := "?styles=" + *steamGridDBStyles + "&types=" + *steamGridDBTypes + "&nsfw=" + *steamGridDBNsfw + "&humor=" + *steamGridDBHumor + "&dimensions=" + *steamGridDBCoverDimensions
-	steamGridDBHeroFilter := "?styles=" + *steamGridDBStyles + "&types=" + *steamGridDBTypes + "&nsfw=" + *steamGridDBNsfw + "&humor=" + *steamGridDBHumor + "&dimensions=" + *steamGridDBHeroDimensions
-	steamGridDBLogoFilter := "?styles=" + *steamGridDBLogoStyles + "&types=" + *steamGridDBTypes + "&nsfw=" + *steamGridDBNsfw + "&humor=" + *steamGridDBHumor
-
-	artStyles := map[string][]string{
-		// artStyle: ["idExtension", "nameExtension", steamUrlExtension, steamGridDbFilter]
-		"Banner": []string{"", ".banner", "header.jpg", steamGridDBBannerFilter},
-		"Cover":  []string{"p", ".cover", "library_600x900_2x.jpg", steamGridDBCoverFilter},
-		"Hero":   []string{"_hero", ".hero", "library_hero.jpg", steamGridDBHeroFilter},
-		"Logo":   []string{"_logo", ".logo", "logo.png", steamGridDBLogoFilter},
+	if cmd != nil {
+		args = args[1:]
 	}
-
-	if *skipBanner {
-		delete(artStyles, "Banner")
-	}
-	if *skipCover {
-		delete(artStyles, "Cover")
-	}
-	if *skipHero {
-		delete(artStyles, "Hero")
-	}
-	if *skipLogo {
-		delete(artStyles, "Logo")
-	}
-	if len(artStyles) == 0 {
-		errorAndExit(errors.New("No artStyles, nothing to do…"))
-	}
-
-	if *skipSteam && *onlyMissingArtwork {
-		errorAndExit(errors.New("Can't check if official artwork is missing with steam turned off"))
+	flag.CommandLine.Parse(args)
+
+	configPath := configFile()
+	if cmd == nil && shouldRunWizard(os.Args[1:], configPath) {
+		err := runWizard(configPath)
+		if err == errWizardAborted {
+			// Carry on with the defaults, like a non-interactive run.
+			fmt.Println(err.Error())
+		} else if err != nil {
+			errorAndExit(err, exitFatal)
+		}
 	}
-
-	fmt.Println("Loading overlays...")
-	overlays, err := LoadOverlays(filepath.Join(filepath.Dir(os.Args[0]), "overlays by category"), artStyles)
+	loadSecrets()
+	err := loadConfig(configPath)
 	if err != nil {
-		errorAndExit(err)
-	}
-	if len(overlays) == 0 {
-		fmt.Println("No category overlays found. You can put overlay images in the folder 'overlays by category', where the filename is the game category.\n\nYou can find many user-created overlays at https://www.reddit.com/r/steamgrid/wiki/overlays .\n\nContinuing without overlays...")
-	} else {
-		fmt.Printf("Loaded %v overlays. \n\nYou can find many user-created overlays at https://www.reddit.com/r/steamgrid/wiki/overlays .\n\n", len(overlays))
+		errorAndExit(err, exitFatal)
 	}
 
-	fmt.Println("Looking for Steam directory...\nIf SteamGrid doesn´t find the directory automatically, launch it with an argument linking to the Steam directory.")
-	installationDir, err := GetSteamInstallation(*steamDir)
-	if err != nil {
-		errorAndExit(err)
-	}
+	options.OverlaysDir = steamgrid.DataDir("overlays by category")
+	options.OverridesDir = steamgrid.DataDir("games")
+	options.CandidatesDir = steamgrid.DataDir("candidates")
 
-	fmt.Println("Loading users...")
-	users, err := GetUsers(installationDir)
-	if err != nil {
-		errorAndExit(err)
-	}
-	if len(users) == 0 {
-		errorAndExit(errors.New("No users found at Steam/userdata. Have you used Steam before in this computer?"))
+	if cmd != nil && cmd.run != nil {
+		err := cmd.run(options, flag.Args())
+		code := exitCode(err)
+		if code == exitFatal {
+			fmt.Println(err.Error())
+		}
+		os.Exit(code)
+	} else if flag.NArg() == 1 {
+		options.SteamDir = flag.Args()[0]
+	} else if flag.NArg() >= 2 {
+		flag.Usage()
+		os.Exit(1)
 	}
 
-	nOverlaysApplied := 0
-	nDownloaded := 0
-	notFounds := map[string][]*Game{
-		"Banner": []*Game{},
-		"Cover":  []*Game{},
-		"Hero":   []*Game{},
-		"Logo":   []*Game{},
-	}
-	steamGridDB := map[string][]*Game{
-		"Banner": []*Game{},
-		"Cover":  []*Game{},
-		"Hero":   []*Game{},
-		"Logo":   []*Game{},
-	}
-	IGDB := map[string][]*Game{
-		"Banner": []*Game{},
-		"Cover":  []*Game{},
-		"Hero":   []*Game{},
-		"Logo":   []*Game{},
-	}
-	searchedGames := map[string][]*Game{
-		"Banner": []*Game{},
-		"Cover":  []*Game{},
-		"Hero":   []*Game{},
-		"Logo":   []*Game{},
+	if *installServiceFlag {
+		if hasSecretArgs(os.Args[1:]) {
+			// Kept out of the scheduled command line, where other users could
+			// read them.
+			err := saveKeys(options)
+			if err != nil {
+				errorAndExit(err, exitFatal)
+			}
+			fmt.Println("Saved the keys in " + keychainName() + " for the scheduled runs.")
+		}
+		err := installService(os.Args[1:])
+		if err != nil {
+			fmt.Println("Failed to install the scheduled run: " + err.Error())
+			os.Exit(exitFatal)
+		}
+		fmt.Println("steamgrid will now run weekly with the given flags.")
+		return
+	} else if *uninstallServiceFlag {
+		err := uninstallService()
+		if err != nil {
+			fmt.Println("Failed to remove the scheduled run: " + err.Error())
+			os.Exit(exitFatal)
+		}
+		fmt.Println("The scheduled run was removed.")
+		return
 	}
-	failedGames := map[string][]*Game{
-		"Banner": []*Game{},
-		"Cover":  []*Game{},
-		"Hero":   []*Game{},
-		"Logo":   []*Game{},
+	if *batch {
+		options.Plain = true
 	}
-	var errorMessages []string
-
-	for _, user := range users {
-		fmt.Println("Loading games for " + user.Name)
-		gridDir := filepath.Join(user.Dir, "config", "grid")
 
-		err = os.MkdirAll(filepath.Join(gridDir, "originals"), 0777)
+	if *saveKeysFlag {
+		err := saveKeys(options)
 		if err != nil {
-			errorAndExit(err)
+			errorAndExit(err, exitFatal)
 		}
-
-		games := GetGames(user, *nonSteamOnly, *appIDs)
-
-		fmt.Println("Loading existing images and backups...")
-
-		i := 0
-		for _, game := range games {
-			i++
-
-			var name string
-			if game.Name == "" {
-				game.Name = getGameName(game.ID)
-			}
-
-			if game.Name != "" {
-				name = game.Name
-			} else {
-				name = "unknown game with id " + game.ID
-			}
-			fmt.Printf("Processing %v (%v/%v)\n", name, i, len(games))
-
-			for artStyle, artStyleExtensions := range artStyles {
-				// Clear for multiple runs:
-				game.ImageSource = ""
-				game.ImageExt = ""
-				game.CleanImageBytes = nil
-				game.OverlayImageBytes = nil
-
-				overridePath := filepath.Join(filepath.Dir(os.Args[0]), "games")
-				loadExisting(overridePath, gridDir, game, artStyleExtensions)
-				// This cleans up unused backups and images for the same game but with different extensions.
-				err = removeExisting(gridDir, game.ID, artStyleExtensions)
-				if err != nil {
-					fmt.Println(err.Error())
-				}
-
-				///////////////////////
-				// Download if missing.
-				///////////////////////
-				if game.ImageSource == "" {
-					from, err := DownloadImage(gridDir, game, artStyle, artStyleExtensions, *skipSteam, *steamGridDBApiKey, *IGDBSecret, *IGDBClient, *skipGoogle, *onlyMissingArtwork)
-					if err != nil && err.Error() == "SteamGridDB authorization token is missing or invalid" {
-						// Wrong api key
-						*steamGridDBApiKey = ""
-						fmt.Println(err.Error())
-					} else if err != nil {
-						fmt.Println(err.Error())
-					}
-
-					if game.ImageSource == "" {
-						notFounds[artStyle] = append(notFounds[artStyle], game)
-						fmt.Printf("%v not found\n", artStyle)
-						// Game has no image, skip it.
-						continue
-					} else if err == nil {
-						nDownloaded++
-					}
-
-					switch from {
-					case "IGDB":
-						IGDB[artStyle] = append(IGDB[artStyle], game)
-					case "SteamGridDB":
-						steamGridDB[artStyle] = append(steamGridDB[artStyle], game)
-					case "search":
-						searchedGames[artStyle] = append(searchedGames[artStyle], game)
-					}
-				}
-				fmt.Printf("%v found from %v\n", artStyle, game.ImageSource)
-
-				///////////////////////
-				// Apply overlay.
-				//
-				// Expecting name.artExt.imgExt:
-				// Banner: favorites.png
-				// Cover: favorites.p.png
-				// Hero: favorites.hero.png
-				// Logo: favorites.logo.png
-				///////////////////////
-				err := ApplyOverlay(game, overlays, artStyleExtensions)
-				if err != nil {
-					print(err.Error(), "\n")
-					failedGames[artStyle] = append(failedGames[artStyle], game)
-					errorMessages = append(errorMessages, err.Error())
-				}
-				if game.OverlayImageBytes != nil {
-					nOverlaysApplied++
-				} else {
-					game.OverlayImageBytes = game.CleanImageBytes
-				}
-
-				///////////////////////
-				// Save result.
-				///////////////////////
-				err = backupGame(gridDir, game, artStyleExtensions)
-				if err != nil {
-					errorAndExit(err)
-				}
-
-				imagePath := filepath.Join(gridDir, game.ID+artStyleExtensions[0]+game.ImageExt)
-				err = ioutil.WriteFile(imagePath, game.OverlayImageBytes, 0666)
-
-				// Copy with legacy naming for Big Picture mode
-				if artStyle == "Banner" {
-					// use appID
-					id, err := strconv.ParseUint(game.ID, 10, 64)
-					if game.LegacyID != 0 {
-						// old target+exe format for custom shortcuts
-						id = game.LegacyID
-					}
-					if err == nil {
-						imagePath := filepath.Join(gridDir, strconv.FormatUint(id<<32|0x02000000, 10)+artStyleExtensions[0]+game.ImageExt)
-						err = ioutil.WriteFile(imagePath, game.OverlayImageBytes, 0666)
-					}
-				}
-				if err != nil {
-					fmt.Printf("Failed to write image for %v (%v) because: %v\n", game.Name, artStyle, err.Error())
-				}
-			}
+		fmt.Println("Saved the keys in " + keychainName() + ", later runs use them without flags.")
+		return
+	} else if *getOverlays != "" {
+		installed, err := steamgrid.InstallOverlayPack(*getOverlays, options.OverlaysDir)
+		if err != nil {
+			errorAndExit(err, exitFatal)
 		}
-	}
-
-	fmt.Printf("\n\n%v images downloaded and %v overlays applied.\n\n", nDownloaded, nOverlaysApplied)
-	if len(searchedGames["Banner"])+len(searchedGames["Cover"])+len(searchedGames["Hero"])+len(searchedGames["Logo"]) >= 1 {
-		fmt.Printf("%v images were found with a Google search and may not be accurate:\n", len(searchedGames["Banner"])+len(searchedGames["Cover"])+len(searchedGames["Hero"])+len(searchedGames["Logo"]))
-		for artStyle, games := range searchedGames {
-			for _, game := range games {
-				fmt.Printf("* %v (steam id %v, %v)\n", game.Name, game.ID, artStyle)
-			}
+		fmt.Printf("Installed %v overlays into %v.\n", len(installed), options.OverlaysDir)
+		return
+	} else if *diff {
+		err := steamgrid.PrintDiff(options)
+		if err != nil {
+			errorAndExit(err, exitFatal)
 		}
-
-		fmt.Printf("\n\n")
-	}
-
-	if len(IGDB["Banner"])+len(IGDB["Cover"]) >= 1 {
-		fmt.Printf("%v images were found on IGDB and may not be in full quality or accurate:\n", len(IGDB["Banner"])+len(IGDB["Cover"]))
-		for artStyle, games := range IGDB {
-			for _, game := range games {
-				fmt.Printf("* %v (steam id %v, %v)\n", game.Name, game.ID, artStyle)
-			}
+		return
+	} else if *rollback != "" {
+		err := steamgrid.Rollback(options, *rollback)
+		if err != nil {
+			errorAndExit(err, exitFatal)
 		}
-
-		fmt.Printf("\n\n")
-	}
-
-	if len(steamGridDB["Banner"])+len(steamGridDB["Cover"])+len(steamGridDB["Hero"])+len(steamGridDB["Logo"]) >= 1 {
-		fmt.Printf("%v images were found on SteamGridDB and may not be in full quality or accurate:\n", len(steamGridDB["Banner"])+len(steamGridDB["Cover"])+len(steamGridDB["Hero"])+len(steamGridDB["Logo"]))
-		for artStyle, games := range steamGridDB {
-			for _, game := range games {
-				fmt.Printf("* %v (steam id %v, %v)\n", game.Name, game.ID, artStyle)
-			}
+		return
+	} else if *pruneBackupsFlag {
+		err := steamgrid.PruneBackups(options, *keepBackups)
+		if err != nil {
+			errorAndExit(err, exitFatal)
 		}
-
-		fmt.Printf("\n\n")
-	}
-
-	if len(notFounds["Banner"])+len(notFounds["Cover"])+len(notFounds["Hero"])+len(notFounds["Logo"]) >= 1 {
-		fmt.Printf("%v images could not be found anywhere:\n", len(notFounds["Banner"])+len(notFounds["Cover"])+len(notFounds["Hero"])+len(notFounds["Logo"]))
-		for artStyle, games := range notFounds {
-			for _, game := range games {
-				fmt.Printf("- %v (id %v, %v)\n", game.Name, game.ID, artStyle)
-			}
+		return
+	} else if *tui {
+		err := steamgrid.RunTUI(options)
+		if err != nil {
+			errorAndExit(err, exitFatal)
 		}
-
-		fmt.Printf("\n\n")
+		return
+	} else if *serve != "" {
+		err := steamgrid.Serve(*serve, options)
+		errorAndExit(err, exitFatal)
+	} else if *startGUI || shouldStartGUI(os.Args[1:]) {
+		err := RunGUI(options, configPath)
+		errorAndExit(err, exitFatal)
 	}
 
-	if len(failedGames["Banner"])+len(failedGames["Cover"])+len(failedGames["Hero"])+len(failedGames["Logo"]) >= 1 {
-		fmt.Printf("%v images were found but had errors and could not be overlaid:\n", len(failedGames["Banner"])+len(failedGames["Cover"])+len(failedGames["Hero"])+len(failedGames["Logo"]))
-		for artStyle, games := range failedGames {
-			var i = 0
-			for _, game := range games {
-				fmt.Printf("- %v (id %v, %v) (%v)\n", game.Name, game.ID, artStyle, errorMessages[i])
-				i++
-			}
+	if globalTimeout, err := time.ParseDuration(options.GlobalTimeout); err == nil && globalTimeout > 0 {
+		// Run stops starting games at the timeout, this is for the ones that
+		// hang anyway.
+		kill := time.AfterFunc(globalTimeout+killGracePeriod, func() {
+			fmt.Println("\nStill running long after the global timeout, quitting.")
+			os.Exit(exitTimeout)
+		})
+		defer kill.Stop()
+	}
+	err = steamgrid.Run(context.Background(), options)
+	code := exitCode(err)
+	if *batch {
+		if code == exitFatal {
+			fmt.Println(err.Error())
 		}
-
-		fmt.Printf("\n\n")
+		os.Exit(code)
+	}
+	if code == exitFatal {
+		errorAndExit(err, code)
 	}
 
 	fmt.Println("Open Steam in grid view to see the results!\n\nPress enter to close.")
 
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
+	os.Exit(code)
 }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/boppreh/steamgrid/pkg/steamgrid"
+)
+
+// Returns true when steamgrid is started for the first time without flags,
+// from a terminal, so the setup wizard should run.
+func shouldRunWizard(args []string, configPath string) bool {
+	if len(args) > 0 || shouldStartGUI(args) {
+		// On Windows the GUI is the first-run setup.
+		return false
+	}
+	if _, err := os.Stat(configPath); err == nil {
+		return false
+	}
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// Returned by runWizard when the input ends before all questions are
+// answered, e.g. when stdin is a closed pipe.
+var errWizardAborted = errors.New("Setup aborted, no more answers. Run \"steamgrid setup\" from a terminal to set up steamgrid")
+
+// Asks for the Steam folder, api keys and art styles, checking them as it
+// goes, and saves the answers to the config file for later runs.
+func runWizard(configPath string) error {
+	reader := bufio.NewReader(os.Stdin)
+	ask := func(question string, defaultAnswer string) (string, error) {
+		if defaultAnswer != "" {
+			fmt.Printf("%v [%v]: ", question, defaultAnswer)
+		} else {
+			fmt.Printf("%v: ", question)
+		}
+		answer, err := reader.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if err == io.EOF && answer == "" {
+			// Asking again would loop forever.
+			fmt.Println()
+			return "", errWizardAborted
+		} else if err != nil && err != io.EOF {
+			return "", err
+		}
+		if answer == "" {
+			return defaultAnswer, nil
+		}
+		return answer, nil
+	}
+
+	values, err := readConfig(configPath)
+	if err != nil {
+		return err
+	}
+	for name, value := range readStoredKeys() {
+		if values[name] == "" {
+			values[name] = value
+		}
+	}
+	fmt.Println("Welcome to steamgrid! A few questions to set it up, press enter to keep the answer in brackets.")
+	fmt.Println()
+
+	installationDir, err := steamgrid.GetSteamInstallation(values["steamdir"])
+	for err != nil {
+		fmt.Println(err.Error())
+		var steamDir string
+		steamDir, err = ask("Steam folder", "")
+		if err != nil {
+			return err
+		}
+		installationDir, err = steamgrid.GetSteamInstallation(steamDir)
+		if err == nil {
+			values["steamdir"] = steamDir
+		}
+	}
+	fmt.Printf("Found Steam in %v.\n", installationDir)
+	users, err := steamgrid.GetUsers(installationDir)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		fmt.Printf("  User %v (%v)\n", user.Name, user.SteamID32)
+	}
+	fmt.Println()
+
+	fmt.Println("A SteamGridDB api key finds artwork for many more games. Get one at https://www.steamgriddb.com/profile/preferences")
+	for {
+		key, err := ask("SteamGridDB api key, empty to skip", values["steamgriddb"])
+		if err != nil {
+			return err
+		} else if key == "" {
+			break
+		}
+		err = steamgrid.CheckSteamGridDBKey(key)
+		if err == nil {
+			values["steamgriddb"] = key
+			break
+		}
+		fmt.Println(err.Error())
+	}
+	fmt.Println()
+
+	fmt.Println("IGDB has covers for games not on Steam. Get a client id and secret at https://api.igdb.com/signup")
+	for {
+		client, err := ask("IGDB client id, empty to skip", values["igdbclient"])
+		if err != nil {
+			return err
+		} else if client == "" {
+			break
+		}
+		secret, err := ask("IGDB client secret", values["igdbsecret"])
+		if err != nil {
+			return err
+		}
+		err = steamgrid.CheckIGDBKeys(client, secret)
+		if err == nil {
+			values["igdbclient"] = client
+			values["igdbsecret"] = secret
+			break
+		}
+		fmt.Println(err.Error())
+	}
+	fmt.Println()
+
+	styleFlags := map[string]string{"banner": "skipbanner", "cover": "skipcover", "hero": "skiphero", "logo": "skiplogo"}
+	for {
+		var current []string
+		for _, style := range []string{"banner", "cover", "hero", "logo"} {
+			if values[styleFlags[style]] != "true" {
+				current = append(current, style)
+			}
+		}
+		styles, err := ask("Art styles to download, comma separated", strings.Join(current, ","))
+		if err != nil {
+			return err
+		}
+		answer := strings.Split(strings.ToLower(styles), ",")
+		valid := true
+		for i, style := range answer {
+			answer[i] = strings.TrimSpace(style)
+			if _, ok := styleFlags[answer[i]]; !ok {
+				fmt.Println("Unknown art style " + style + ", must be banner, cover, hero or logo")
+				valid = false
+			}
+		}
+		if !valid {
+			continue
+		}
+		chosen := map[string]bool{}
+		for _, style := range answer {
+			chosen[style] = true
+		}
+		for style, flagName := range styleFlags {
+			if chosen[style] {
+				delete(values, flagName)
+			} else {
+				values[flagName] = "true"
+			}
+		}
+		break
+	}
+	if values["steamgriddb"] != "" {
+		defaultStyles := values["styles"]
+		if defaultStyles == "" {
+			defaultStyles = "alternate"
+		}
+		values["styles"], err = ask("SteamGridDB styles (alternate, blurred, white_logo, material, no_logo)", defaultStyles)
+		if err != nil {
+			return err
+		}
+	}
+
+	keys := make(map[string]string)
+	for _, name := range secretFlags {
+		if values[name] != "" {
+			keys[name] = values[name]
+		}
+	}
+	if len(keys) > 0 {
+		err := storeKeys(keys)
+		if err == nil {
+			fmt.Println("Saved the api keys in " + keychainName() + ".")
+			for name := range keys {
+				delete(values, name)
+			}
+		} else {
+			fmt.Println(err.Error() + ". They will be saved in the config file instead.")
+		}
+	}
+
+	err = saveConfig(configPath, values)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nSaved to %v. Run \"steamgrid setup\" to change it, flags given in the command line always win.\n\n", configPath)
+	return nil
+}